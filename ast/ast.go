@@ -2,13 +2,17 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"nexus/token"
+	"strconv"
 	"strings"
 )
 
 type Node interface {
 	TokenLiteral() string
 	AsString() string
+	Pos() token.Position // Where the node starts
+	End() token.Position // Just past the node's last character
 }
 
 type Statement interface {
@@ -33,6 +37,20 @@ func (p *Program) AsString() string {
 	return out.String()
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 func (p *Program) TokenLiteral() string {
 	if len(p.Statements) > 0 {
 		return p.Statements[0].TokenLiteral()
@@ -51,6 +69,8 @@ func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position { return endOf(ls.Value, ls.Name.End()) }
 func (ls *LetStatement) AsString() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
@@ -73,6 +93,8 @@ func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
+func (i *Identifier) End() token.Position { return i.Token.End }
 func (i *Identifier) AsString() string {
 	return i.Value
 }
@@ -86,12 +108,14 @@ func (r *ReturnStatement) statementNode() {}
 func (r *ReturnStatement) TokenLiteral() string {
 	return r.Token.Literal
 }
+func (r *ReturnStatement) Pos() token.Position { return r.Token.Pos }
+func (r *ReturnStatement) End() token.Position { return endOf(r.ReturnValue, r.Token.End) }
 func (r *ReturnStatement) AsString() string {
 	var out bytes.Buffer
-	out.WriteString(r.TokenLiteral() + " ")
+	out.WriteString(r.TokenLiteral())
 
 	if r.ReturnValue != nil {
-		out.WriteString(r.ReturnValue.AsString())
+		out.WriteString(" " + r.ReturnValue.AsString())
 	}
 	out.WriteString(";")
 	return out.String()
@@ -107,6 +131,8 @@ func (cs *ConstStatement) statementNode() {}
 func (cs *ConstStatement) TokenLiteral() string {
 	return cs.Token.Literal
 }
+func (cs *ConstStatement) Pos() token.Position { return cs.Token.Pos }
+func (cs *ConstStatement) End() token.Position { return endOf(cs.Value, cs.Name.End()) }
 func (cs *ConstStatement) AsString() string {
 	var out bytes.Buffer
 	out.WriteString(cs.TokenLiteral() + " ")
@@ -129,6 +155,8 @@ func (e *ExpressionStatement) statementNode() {}
 func (e *ExpressionStatement) TokenLiteral() string {
 	return e.Token.Literal
 }
+func (e *ExpressionStatement) Pos() token.Position { return e.Token.Pos }
+func (e *ExpressionStatement) End() token.Position { return endOf(e.Expression, e.Token.End) }
 func (e *ExpressionStatement) AsString() string {
 	if e.Expression != nil {
 		return e.Expression.AsString()
@@ -139,16 +167,115 @@ func (e *ExpressionStatement) AsString() string {
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int // Set instead of Value when the literal does not fit in an int64
 }
 
 func (i *IntegerLiteral) expressionNode() {}
 func (i *IntegerLiteral) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *IntegerLiteral) Pos() token.Position { return i.Token.Pos }
+func (i *IntegerLiteral) End() token.Position { return i.Token.End }
 func (i *IntegerLiteral) AsString() string {
 	return i.Token.Literal
 }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position  { return fl.Token.End }
+func (fl *FloatLiteral) AsString() string     { return fl.Token.Literal }
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
+func (sl *StringLiteral) AsString() string     { return strconv.Quote(sl.Value) }
+
+type ArrayLiteral struct {
+	Token    token.Token // The '[' token
+	Elements []Expression
+	Close    token.Token // The closing ']' token
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position  { return al.Close.End }
+func (al *ArrayLiteral) AsString() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.AsString())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token token.Token // The '[' token
+	Left  Expression
+	Index Expression
+	Close token.Token // The closing ']' token
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *IndexExpression) End() token.Position  { return ie.Close.End }
+func (ie *IndexExpression) AsString() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ie.Left.AsString())
+	out.WriteString("[")
+	out.WriteString(ie.Index.AsString())
+	out.WriteString("])")
+	return out.String()
+}
+
+type HashLiteral struct {
+	Token token.Token // The '{' token
+	Pairs []HashPair
+	Close token.Token // The closing '}' token
+}
+
+// HashPair is a single `key: value` entry of a hash
+// literal. Pairs are kept in source order here; the
+// evaluated hash orders them by key, see
+// object.Hash.SortedPairs.
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position  { return hl.Close.End }
+func (hl *HashLiteral) AsString() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.AsString()+": "+pair.Value.AsString())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -159,6 +286,8 @@ func (pe *PrefixExpression) expressionNode() {}
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position { return endOf(pe.Right, pe.Token.End) }
 func (pe *PrefixExpression) AsString() string {
 	var out bytes.Buffer
 
@@ -181,6 +310,8 @@ func (in *InfixExpression) expressionNode() {}
 func (in *InfixExpression) TokenLiteral() string {
 	return in.Token.Literal
 }
+func (in *InfixExpression) Pos() token.Position { return in.Left.Pos() }
+func (in *InfixExpression) End() token.Position { return endOf(in.Right, in.Token.End) }
 func (in *InfixExpression) AsString() string {
 	var out bytes.Buffer
 
@@ -193,6 +324,58 @@ func (in *InfixExpression) AsString() string {
 	return out.String()
 }
 
+// LogicalExpression is a short-circuiting `&&` or `||`.
+// Right is only evaluated when Left does not decide the
+// result on its own.
+type LogicalExpression struct {
+	Token    token.Token // The '&&' or '||' token
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (le *LogicalExpression) expressionNode()      {}
+func (le *LogicalExpression) TokenLiteral() string { return le.Token.Literal }
+func (le *LogicalExpression) Pos() token.Position  { return le.Left.Pos() }
+func (le *LogicalExpression) End() token.Position  { return endOf(le.Right, le.Token.End) }
+func (le *LogicalExpression) AsString() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(le.Left.AsString())
+	out.WriteString(" " + le.Operator + " ")
+	out.WriteString(le.Right.AsString())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// AssignExpression covers plain (`x = v`) and compound
+// (`x += v`) assignment. Target is either an *Identifier
+// or an *IndexExpression.
+type AssignExpression struct {
+	Token    token.Token // The assignment operator token
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return ae.Target.Pos() }
+func (ae *AssignExpression) End() token.Position  { return endOf(ae.Value, ae.Token.End) }
+func (ae *AssignExpression) AsString() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Target.AsString())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.AsString())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -200,6 +383,8 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End }
 func (b *Boolean) AsString() string     { return b.Token.Literal }
 
 type IfExpression struct {
@@ -211,6 +396,13 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	return blockEnd(ie.Consequence, ie.Token.End)
+}
 func (ie *IfExpression) AsString() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -227,10 +419,13 @@ func (ie *IfExpression) AsString() string {
 type BlockStatement struct {
 	Token      token.Token // Actual '{' token
 	Statements []Statement
+	Close      token.Token // The closing '}' token
 }
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position  { return bs.Close.End }
 func (bs *BlockStatement) AsString() string {
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
@@ -239,6 +434,67 @@ func (bs *BlockStatement) AsString() string {
 	return out.String()
 }
 
+type WhileStatement struct {
+	Token     token.Token // The 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Position  { return blockEnd(ws.Body, ws.Token.End) }
+func (ws *WhileStatement) AsString() string {
+	var out bytes.Buffer
+	out.WriteString("while")
+	out.WriteString(ws.Condition.AsString())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.AsString())
+	return out.String()
+}
+
+type ForStatement struct {
+	Token    token.Token // The 'for' token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForStatement) End() token.Position  { return blockEnd(fs.Body, fs.Token.End) }
+func (fs *ForStatement) AsString() string {
+	var out bytes.Buffer
+	out.WriteString("for ")
+	out.WriteString(fs.Variable.AsString())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.AsString())
+	out.WriteString(" ")
+	out.WriteString(fs.Body.AsString())
+	return out.String()
+}
+
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position  { return bs.Token.End }
+func (bs *BreakStatement) AsString() string     { return bs.Token.Literal + ";" }
+
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position  { return cs.Token.End }
+func (cs *ContinueStatement) AsString() string     { return cs.Token.Literal + ";" }
+
 type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
@@ -247,6 +503,8 @@ type FunctionLiteral struct {
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position  { return blockEnd(fl.Body, fl.Token.End) }
 func (fl *FunctionLiteral) AsString() string {
 	var out bytes.Buffer
 	params := []string{}
@@ -265,10 +523,13 @@ type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Close     token.Token // The closing ')' token
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Function.Pos() }
+func (ce *CallExpression) End() token.Position  { return ce.Close.End }
 func (ce *CallExpression) AsString() string {
 	var out bytes.Buffer
 	args := []string{}
@@ -281,3 +542,19 @@ func (ce *CallExpression) AsString() string {
 	out.WriteString(")")
 	return out.String()
 }
+
+// endOf returns the end of n, or fallback when n is
+// missing, as happens after a parse error.
+func endOf(n Node, fallback token.Position) token.Position {
+	if n == nil {
+		return fallback
+	}
+	return n.End()
+}
+
+func blockEnd(b *BlockStatement, fallback token.Position) token.Position {
+	if b == nil {
+		return fallback
+	}
+	return b.End()
+}
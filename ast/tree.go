@@ -0,0 +1,151 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Tree renders node and everything below it, one node per
+// line indented by depth, along with where each one starts.
+// Children whose role is not obvious are labelled.
+//
+//	Program 1:1
+//	  LetStatement x 1:1
+//	    InfixExpression + 1:9
+//	      IntegerLiteral 1 1:9
+//	      IntegerLiteral 2 1:13
+func Tree(node Node) string {
+	var out bytes.Buffer
+	writeTree(&out, "", node, 0)
+	return out.String()
+}
+
+// Dump writes node flat, as AsString gives it, then as a
+// Tree. This is what the REPL :ast and `nexus ast` print.
+func Dump(w io.Writer, node Node) {
+	fmt.Fprintln(w, node.AsString())
+	io.WriteString(w, Tree(node))
+}
+
+type treeChild struct {
+	label string
+	node  Node
+}
+
+func writeTree(out *bytes.Buffer, label string, node Node, depth int) {
+	detail, children := describe(node)
+
+	out.WriteString(strings.Repeat("  ", depth))
+	if label != "" {
+		out.WriteString(label + ": ")
+	}
+	out.WriteString(strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."))
+	if detail != "" {
+		out.WriteString(" " + detail)
+	}
+	out.WriteString(" " + node.Pos().String() + "\n")
+
+	for _, child := range children {
+		writeTree(out, child.label, child.node, depth+1)
+	}
+}
+
+// describe returns what sets node apart from other nodes
+// of its type, and its children. Missing children, as left
+// by parse errors, are skipped.
+func describe(node Node) (string, []treeChild) {
+	var children []treeChild
+	add := func(label string, n Node) {
+		if n != nil {
+			children = append(children, treeChild{label, n})
+		}
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, s := range node.Statements {
+			add("", s)
+		}
+	case *LetStatement:
+		add("", node.Value)
+		return node.Name.Value, children
+	case *ConstStatement:
+		add("", node.Value)
+		return node.Name.Value, children
+	case *ReturnStatement:
+		add("", node.ReturnValue)
+	case *ExpressionStatement:
+		add("", node.Expression)
+	case *BlockStatement:
+		for _, s := range node.Statements {
+			add("", s)
+		}
+	case *Identifier:
+		return node.Value, nil
+	case *IntegerLiteral, *FloatLiteral, *StringLiteral, *Boolean:
+		return node.AsString(), nil
+	case *ArrayLiteral:
+		for _, el := range node.Elements {
+			add("", el)
+		}
+	case *IndexExpression:
+		add("left", node.Left)
+		add("index", node.Index)
+	case *HashLiteral:
+		for _, pair := range node.Pairs {
+			add("key", pair.Key)
+			add("value", pair.Value)
+		}
+	case *PrefixExpression:
+		add("", node.Right)
+		return node.Operator, children
+	case *InfixExpression:
+		add("", node.Left)
+		add("", node.Right)
+		return node.Operator, children
+	case *LogicalExpression:
+		add("", node.Left)
+		add("", node.Right)
+		return node.Operator, children
+	case *AssignExpression:
+		add("target", node.Target)
+		add("value", node.Value)
+		return node.Operator, children
+	case *IfExpression:
+		add("condition", node.Condition)
+		if node.Consequence != nil {
+			add("consequence", node.Consequence)
+		}
+		if node.Alternative != nil {
+			add("alternative", node.Alternative)
+		}
+	case *WhileStatement:
+		add("condition", node.Condition)
+		if node.Body != nil {
+			add("body", node.Body)
+		}
+	case *ForStatement:
+		add("iterable", node.Iterable)
+		if node.Body != nil {
+			add("body", node.Body)
+		}
+		return node.Variable.Value, children
+	case *FunctionLiteral:
+		params := []string{}
+		for _, p := range node.Parameters {
+			params = append(params, p.Value)
+		}
+		if node.Body != nil {
+			add("body", node.Body)
+		}
+		return "(" + strings.Join(params, ", ") + ")", children
+	case *CallExpression:
+		add("function", node.Function)
+		for _, arg := range node.Arguments {
+			add("argument", arg)
+		}
+	}
+	return "", children
+}
@@ -0,0 +1,326 @@
+// Package cli implements the nexus command.
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"nexus/ast"
+	"nexus/diagnostic"
+	"nexus/evaluator"
+	"nexus/format"
+	"nexus/lexer"
+	"nexus/object"
+	"nexus/parser"
+	"nexus/repl"
+	"os"
+	"strings"
+)
+
+// Exit codes, besides the ones given to `exit`.
+const (
+	exitOK    = 0
+	exitError = 1 // The program has errors
+	exitUsage = 2 // The command line is wrong
+)
+
+type command struct {
+	usage string
+	help  string
+	run   func(c *cli, args []string) int
+}
+
+// commands maps subcommand names to their handlers. The
+// table is built in init: help reads it, so initializing
+// it in its declaration would be a cycle.
+var commands map[string]command
+
+// commandOrder is the order help lists commands in.
+var commandOrder = []string{"run", "repl", "check", "fmt", "tokens", "ast", "help"}
+
+func init() {
+	commands = map[string]command{
+		"run":    {"run <file> [args...]", "run a program, args are in `args`", (*cli).run},
+		"repl":   {"repl", "start an interactive session", (*cli).repl},
+		"check":  {"check [-json] <file>...", "report the problems of files without running them", (*cli).check},
+		"tokens": {"tokens <file>", "list the tokens of a file", (*cli).tokens},
+		"ast":    {"ast <file>", "show how a file parses, flat and as a tree", (*cli).ast},
+		"fmt":    {"fmt [-w] <file>...", "print files formatted, or with -w rewrite them", (*cli).format},
+		"help":   {"help", "list the commands", (*cli).help},
+	}
+}
+
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Main runs the nexus command with the given arguments,
+// not including the program name, and returns the exit
+// code. A file name of "-" reads the program from stdin.
+//
+// Without arguments it starts a session; with a file
+// name first, it runs that file, so scripts can start
+// with a `#!/usr/bin/env nexus` line.
+func Main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	if len(args) == 0 {
+		return c.repl(nil)
+	}
+
+	switch name := args[0]; {
+	case name == "-e":
+		if len(args) < 2 {
+			return c.usageError("-e needs a program")
+		}
+		return c.exec("-e", args[1], args[2:])
+	case name == "-h" || name == "-help" || name == "--help":
+		return c.help(nil)
+	case name != "-" && strings.HasPrefix(name, "-"):
+		return c.usageError("unknown flag %s", name)
+	}
+
+	if cmd, ok := commands[args[0]]; ok {
+		return cmd.run(c, args[1:])
+	}
+	return c.run(args)
+}
+
+// usageError reports a wrong command line.
+func (c *cli) usageError(format string, a ...any) int {
+	fmt.Fprintf(c.stderr, "nexus: "+format+"\n", a...)
+	fmt.Fprintln(c.stderr, "run 'nexus help' for usage")
+	return exitUsage
+}
+
+// usage reports a command called with the wrong
+// arguments.
+func (c *cli) usage(name string) int {
+	fmt.Fprintf(c.stderr, "usage: nexus %s\n", commands[name].usage)
+	return exitUsage
+}
+
+// read returns the contents of the named file, and the
+// name positions should carry.
+func (c *cli) read(name string) (string, string, error) {
+	if name == "-" {
+		src, err := io.ReadAll(c.stdin)
+		return string(src), "<stdin>", err
+	}
+	src, err := os.ReadFile(name)
+	return string(src), name, err
+}
+
+// parse parses src, reporting its problems if any.
+func (c *cli) parse(filename, src string) (*ast.Program, bool) {
+	par := parser.New(lexer.NewFile(filename, src))
+	prog := par.ParseProgram()
+	if err := par.Errors(); len(err) > 0 {
+		c.printDiagnostics(err, src)
+		return nil, false
+	}
+	return prog, true
+}
+
+func (c *cli) printDiagnostics(diagnostics []*diagnostic.Diagnostic, source string) {
+	color := useColor(c.stderr)
+	for _, d := range diagnostics {
+		diagnostic.Render(c.stderr, d, source, color)
+	}
+}
+
+// useColor reports whether w is a terminal, and the
+// user has not asked for no color with NO_COLOR.
+func useColor(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (c *cli) run(args []string) int {
+	if len(args) == 0 {
+		return c.usage("run")
+	}
+	src, filename, err := c.read(args[0])
+	if err != nil {
+		fmt.Fprintf(c.stderr, "nexus: %v\n", err)
+		return exitError
+	}
+	return c.exec(filename, src, args[1:])
+}
+
+// exec runs a program with `args` bound to the given
+// arguments. Its exit code is the one given to `exit`,
+// if it called it.
+func (c *cli) exec(filename, src string, args []string) int {
+	prog, ok := c.parse(filename, src)
+	if !ok {
+		return exitError
+	}
+
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+	env := object.NewEnvironment()
+	env.Set("args", &object.Array{Elements: elements})
+
+	output := evaluator.Output
+	evaluator.Output = c.stdout
+	defer func() { evaluator.Output = output }()
+
+	switch result := evaluator.Eval(prog, env).(type) {
+	case *object.Exit:
+		return result.Code
+	case *object.Error:
+		c.printDiagnostics([]*diagnostic.Diagnostic{result.Diagnostic()}, src)
+		return exitError
+	}
+	return exitOK
+}
+
+func (c *cli) repl(args []string) int {
+	if len(args) > 0 {
+		return c.usageError("repl takes no arguments")
+	}
+	repl.Start(c.stdin, c.stdout)
+	return exitOK
+}
+
+// check parses files and reports their problems, as
+// rendered text on stderr or, with -json, as JSON lines
+// on stdout.
+func (c *cli) check(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	asJSON := flags.Bool("json", false, "report problems as JSON lines")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		return c.usage("check")
+	}
+
+	code := exitOK
+	for _, name := range flags.Args() {
+		src, filename, err := c.read(name)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "nexus: %v\n", err)
+			code = exitError
+			continue
+		}
+
+		par := parser.New(lexer.NewFile(filename, src))
+		par.ParseProgram()
+		diagnostics := par.Errors()
+		if len(diagnostics) == 0 {
+			continue
+		}
+
+		code = exitError
+		if *asJSON {
+			diagnostic.WriteJSON(c.stdout, diagnostics)
+		} else {
+			c.printDiagnostics(diagnostics, src)
+		}
+	}
+	return code
+}
+
+func (c *cli) tokens(args []string) int {
+	if len(args) != 1 {
+		return c.usage("tokens")
+	}
+	src, filename, err := c.read(args[0])
+	if err != nil {
+		fmt.Fprintf(c.stderr, "nexus: %v\n", err)
+		return exitError
+	}
+
+	lex := lexer.NewFile(filename, src)
+	lexer.WriteTokens(c.stdout, lex)
+	if err := lex.Errors(); len(err) > 0 {
+		c.printDiagnostics(err, src)
+		return exitError
+	}
+	return exitOK
+}
+
+func (c *cli) ast(args []string) int {
+	if len(args) != 1 {
+		return c.usage("ast")
+	}
+	src, filename, err := c.read(args[0])
+	if err != nil {
+		fmt.Fprintf(c.stderr, "nexus: %v\n", err)
+		return exitError
+	}
+	prog, ok := c.parse(filename, src)
+	if !ok {
+		return exitError
+	}
+	ast.Dump(c.stdout, prog)
+	return exitOK
+}
+
+// format prints files in the canonical layout on stdout
+// or, with -w, writes it back to them. Files with syntax
+// errors are reported and left as they are.
+func (c *cli) format(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	write := flags.Bool("w", false, "write the result to the files")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		return c.usage("fmt")
+	}
+
+	code := exitOK
+	for _, name := range flags.Args() {
+		src, filename, err := c.read(name)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "nexus: %v\n", err)
+			code = exitError
+			continue
+		}
+
+		out, diagnostics := format.Source(filename, src)
+		if len(diagnostics) > 0 {
+			c.printDiagnostics(diagnostics, src)
+			code = exitError
+			continue
+		}
+
+		if !*write || name == "-" {
+			io.WriteString(c.stdout, out)
+			continue
+		}
+		if out == src {
+			continue
+		}
+		if err := os.WriteFile(name, []byte(out), 0o644); err != nil {
+			fmt.Fprintf(c.stderr, "nexus: %v\n", err)
+			code = exitError
+		}
+	}
+	return code
+}
+
+func (c *cli) help([]string) int {
+	fmt.Fprintln(c.stdout, "usage: nexus [command] [args...]")
+	fmt.Fprintln(c.stdout, "       nexus <file> [args...]")
+	fmt.Fprintln(c.stdout, "       nexus -e <src> [args...]")
+	fmt.Fprintln(c.stdout)
+	for _, name := range commandOrder {
+		cmd := commands[name]
+		fmt.Fprintf(c.stdout, "  %-24s %s\n", cmd.usage, cmd.help)
+	}
+	return exitOK
+}
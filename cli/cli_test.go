@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runMain(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Main(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeFile(t *testing.T, name, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	path := writeFile(t, "args.nx", "#!/usr/bin/env nexus\nputs(len(args));\nfor a in args { puts(a) }\n")
+
+	for _, args := range [][]string{{"run", path, "x", "y"}, {path, "x", "y"}} {
+		code, stdout, stderr := runMain(t, "", args...)
+		if code != 0 || stderr != "" {
+			t.Errorf("%v: unexpected failure. code=%d stderr=%q", args, code, stderr)
+		}
+		if stdout != "2\nx\ny\n" {
+			t.Errorf("%v: wrong output. got=%q", args, stdout)
+		}
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected int
+	}{
+		{[]string{"-e", "1 + 1"}, 0},
+		{[]string{"-e", "exit(3); puts(1)"}, 3},
+		{[]string{"-e", "exit(int(args[0]))", "7"}, 7},
+		{[]string{"-e", "1 / 0"}, 1},
+		{[]string{"-e", "let = 1"}, 1},
+		{[]string{"missing.nx"}, 1},
+		{[]string{"-e"}, 2},
+		{[]string{"run"}, 2},
+		{[]string{"check"}, 2},
+		{[]string{"fmt"}, 2},
+		{[]string{"repl", "x"}, 2},
+		{[]string{"--nope"}, 2},
+	}
+
+	for _, tt := range tests {
+		if code, _, _ := runMain(t, "", tt.args...); code != tt.expected {
+			t.Errorf("%v: wrong exit code. expected=%d, got=%d", tt.args, tt.expected, code)
+		}
+	}
+}
+
+func TestRuntimeErrorIsRendered(t *testing.T) {
+	_, _, stderr := runMain(t, "let x = 1;\nx + true\n", "run", "-")
+
+	for _, want := range []string{"error[R001]: type mismatch: INTEGER + BOOLEAN", "--> <stdin>:2:1", "x + true"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("expected %q in stderr. got=%q", want, stderr)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	good := writeFile(t, "good.nx", "let x = 1;\n")
+	bad := writeFile(t, "bad.nx", "let = 1;\nputs(1 / 0)\n")
+
+	if code, stdout, stderr := runMain(t, "", "check", good); code != 0 || stdout != "" || stderr != "" {
+		t.Errorf("good file: code=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+
+	code, _, stderr := runMain(t, "", "check", good, bad)
+	if code != 1 || !strings.Contains(stderr, "error[P001]") || !strings.Contains(stderr, bad+":1:5") {
+		t.Errorf("bad file: code=%d stderr=%q", code, stderr)
+	}
+
+	code, stdout, stderr := runMain(t, "", "check", "-json", bad)
+	if code != 1 || stderr != "" {
+		t.Errorf("json: code=%d stderr=%q", code, stderr)
+	}
+	if lines := strings.Split(strings.TrimSpace(stdout), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"code":"P001"`) {
+		t.Errorf("json: wrong output. got=%q", stdout)
+	}
+}
+
+func TestTokensAndAst(t *testing.T) {
+	code, stdout, _ := runMain(t, "let x = 1", "tokens", "-")
+	if code != 0 || !strings.HasPrefix(stdout, `<stdin>:1:1 LET       "let"`) {
+		t.Errorf("tokens: code=%d stdout=%q", code, stdout)
+	}
+
+	code, stdout, _ = runMain(t, "let x = 1", "ast", "-")
+	if code != 0 || !strings.Contains(stdout, "LetStatement x <stdin>:1:1") {
+		t.Errorf("ast: code=%d stdout=%q", code, stdout)
+	}
+
+	if code, _, _ := runMain(t, `"open`, "tokens", "-"); code != 1 {
+		t.Errorf("tokens: expected code 1 on lexer errors, got=%d", code)
+	}
+}
+
+func TestFmt(t *testing.T) {
+	code, stdout, stderr := runMain(t, "let x=1 // one\nputs( x )", "fmt", "-")
+	if code != 0 || stderr != "" || stdout != "let x = 1; // one\nputs(x);\n" {
+		t.Errorf("fmt: code=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+
+	path := writeFile(t, "messy.nx", "let f=fn(a){\na*2}\n")
+	if code, stdout, stderr := runMain(t, "", "fmt", "-w", path); code != 0 || stdout != "" || stderr != "" {
+		t.Errorf("fmt -w: code=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+	if src, _ := os.ReadFile(path); string(src) != "let f = fn(a) {\n    a * 2\n};\n" {
+		t.Errorf("fmt -w: wrong file contents. got=%q", src)
+	}
+
+	bad := writeFile(t, "bad.nx", "let = 1\n")
+	code, _, stderr = runMain(t, "", "fmt", "-w", bad)
+	if code != 1 || !strings.Contains(stderr, "error[P001]") {
+		t.Errorf("fmt bad file: code=%d stderr=%q", code, stderr)
+	}
+	if src, _ := os.ReadFile(bad); string(src) != "let = 1\n" {
+		t.Errorf("fmt bad file: file was changed. got=%q", src)
+	}
+}
+
+func TestRepl(t *testing.T) {
+	code, stdout, _ := runMain(t, "puts(1)\n")
+	if code != 0 || !strings.Contains(stdout, "1\n") {
+		t.Errorf("repl: code=%d stdout=%q", code, stdout)
+	}
+}
@@ -0,0 +1,104 @@
+// Package diagnostic describes the problems found in
+// Nexus code, from lexing to evaluation, in a form that
+// both people and tools can consume.
+package diagnostic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"nexus/token"
+)
+
+// Severity tells how serious a diagnostic is.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+var severityNames = map[Severity]string{
+	Error:   "error",
+	Warning: "warning",
+	Note:    "note",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Code identifies the kind of a diagnostic. Codes never
+// change meaning, so tools can rely on them. The letter
+// tells the stage that reports it: Lexer, Parser or
+// Runtime.
+type Code string
+
+const (
+	UnexpectedCharacter Code = "L001"
+	UnterminatedString  Code = "L002"
+	InvalidEscape       Code = "L003"
+	UnterminatedComment Code = "L004"
+	MalformedNumber     Code = "L005"
+
+	UnexpectedToken     Code = "P001" // A specific token was expected
+	ExpectedExpression  Code = "P002"
+	InvalidAssignTarget Code = "P003"
+	LoopControlOutside  Code = "P004" // break or continue outside a loop
+	InvalidLiteral      Code = "P005"
+
+	RuntimeError Code = "R001"
+)
+
+// Diagnostic is a problem found in the source, spanning
+// from Pos up to, but not including, End.
+type Diagnostic struct {
+	Severity Severity       `json:"severity"`
+	Code     Code           `json:"code"`
+	Pos      token.Position `json:"start"`
+	End      token.Position `json:"end"`
+	Message  string         `json:"message"`
+	Expected []string       `json:"expected,omitempty"` // Tokens that would have been valid
+	Got      string         `json:"got,omitempty"`      // Token found instead
+	Notes    []string       `json:"notes,omitempty"`
+}
+
+// Errorf creates an error diagnostic with a formatted message.
+func Errorf(code Code, pos, end token.Position, format string, a ...any) *Diagnostic {
+	return &Diagnostic{
+		Severity: Error,
+		Code:     code,
+		Pos:      pos,
+		End:      end,
+		Message:  fmt.Sprintf(format, a...),
+	}
+}
+
+// Error renders the diagnostic on one line, prefixed
+// with its position, so it can be used as a Go error.
+func (d *Diagnostic) Error() string {
+	if d.Pos.IsValid() {
+		return d.Pos.String() + ": " + d.Message
+	}
+	return d.Message
+}
+
+// WriteJSON writes the diagnostics as JSON, one
+// object per line.
+func WriteJSON(w io.Writer, diagnostics []*Diagnostic) error {
+	enc := json.NewEncoder(w)
+	for _, d := range diagnostics {
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
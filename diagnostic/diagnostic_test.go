@@ -0,0 +1,128 @@
+package diagnostic
+
+import (
+	"bytes"
+	"encoding/json"
+	"nexus/token"
+	"strings"
+	"testing"
+)
+
+func pos(offset, runeOffset, line, column int) token.Position {
+	return token.Position{Offset: offset, RuneOffset: runeOffset, Line: line, Column: column}
+}
+
+func TestError(t *testing.T) {
+	d := Errorf(UnexpectedToken, pos(4, 4, 2, 3), pos(5, 5, 2, 4), "bad %s", "thing")
+	if d.Error() != "2:3: bad thing" {
+		t.Errorf("wrong Error(). got=%q", d.Error())
+	}
+
+	d = Errorf(RuntimeError, token.Position{}, token.Position{}, "no position")
+	if d.Error() != "no position" {
+		t.Errorf("wrong Error(). got=%q", d.Error())
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		source   string
+		d        *Diagnostic
+		expected string
+	}{
+		{
+			"let x = 1;\nlet y = x +* 2;",
+			&Diagnostic{Code: ExpectedExpression, Pos: pos(22, 22, 2, 12), End: pos(23, 23, 2, 13), Message: "oops"},
+			`error[P002]: oops
+ --> 2:12
+  |
+2 | let y = x +* 2;
+  |            ^
+`,
+		},
+		{
+			"\tlet año = truee",
+			&Diagnostic{Code: RuntimeError, Pos: pos(12, 11, 1, 12), End: pos(17, 16, 1, 17), Message: "identifier not found: truee"},
+			"error[R001]: identifier not found: truee\n" +
+				" --> 1:12\n" +
+				"  |\n" +
+				"1 | \tlet año = truee\n" +
+				"  | \t          ^^^^^\n",
+		},
+		{
+			"if (x) {\n  1\n",
+			&Diagnostic{
+				Severity: Warning,
+				Code:     UnexpectedToken,
+				Pos:      pos(7, 7, 1, 8),
+				End:      pos(13, 13, 3, 1),
+				Message:  "multi-line",
+				Notes:    []string{"first note", "second note"},
+			},
+			`warning[P001]: multi-line
+ --> 1:8
+  |
+1 | if (x) {
+  |        ^
+  = note: first note
+  = note: second note
+`,
+		},
+		{
+			"",
+			&Diagnostic{Code: RuntimeError, Message: "no source", Notes: []string{"a note"}},
+			"error[R001]: no source\n = note: a note\n",
+		},
+	}
+
+	for i, tt := range tests {
+		var out bytes.Buffer
+		Render(&out, tt.d, tt.source, false)
+		if out.String() != tt.expected {
+			t.Errorf("tests[%d] - wrong rendering. expected=\n%s\ngot=\n%s", i, tt.expected, out.String())
+		}
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	var out bytes.Buffer
+	d := &Diagnostic{Code: RuntimeError, Pos: pos(0, 0, 1, 1), End: pos(1, 1, 1, 2), Message: "m"}
+	Render(&out, d, "x", true)
+
+	if !strings.Contains(out.String(), "\x1b[1;31merror[R001]\x1b[0m") {
+		t.Errorf("expected a red severity. got=%q", out.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	d := &Diagnostic{
+		Code:     UnexpectedToken,
+		Pos:      token.Position{Filename: "a.nx", Offset: 3, RuneOffset: 3, Line: 1, Column: 4},
+		End:      token.Position{Filename: "a.nx", Offset: 4, RuneOffset: 4, Line: 1, Column: 5},
+		Message:  "Expected token to be ), got ; instead",
+		Expected: []string{")"},
+		Got:      ";",
+	}
+
+	var out bytes.Buffer
+	if err := WriteJSON(&out, []*Diagnostic{d, d}); err != nil {
+		t.Fatalf("WriteJSON failed: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &decoded); err != nil {
+		t.Fatalf("invalid JSON %q: %s", lines[0], err)
+	}
+	if decoded["severity"] != "error" || decoded["code"] != "P001" || decoded["got"] != ";" {
+		t.Errorf("wrong fields. got=%v", decoded)
+	}
+	start := decoded["start"].(map[string]any)
+	if start["file"] != "a.nx" || start["line"] != 1.0 || start["column"] != 4.0 {
+		t.Errorf("wrong start. got=%v", start)
+	}
+}
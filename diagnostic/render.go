@@ -0,0 +1,105 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorBlue  = "\x1b[1;34m"
+)
+
+var severityColors = map[Severity]string{
+	Error:   "\x1b[1;31m",
+	Warning: "\x1b[1;33m",
+	Note:    "\x1b[1;36m",
+}
+
+// Render writes d for people to read: the severity, code
+// and message, then the offending line of source with the
+// span underlined, then the notes. Source must be the text
+// d was found in; without it only the message and notes
+// are written. Color adds ANSI escape codes.
+//
+//	error[P001]: Expected token to be ), got EOF instead
+//	 --> main.nx:1:15
+//	  |
+//	1 | let x = (1 + 2
+//	  |               ^
+func Render(w io.Writer, d *Diagnostic, source string, color bool) {
+	paint := func(style, s string) string {
+		if !color {
+			return s
+		}
+		return style + s + colorReset
+	}
+	severity := severityColors[d.Severity]
+
+	fmt.Fprintf(w, "%s%s\n",
+		paint(severity, fmt.Sprintf("%s[%s]", d.Severity, d.Code)),
+		paint(colorBold, ": "+d.Message))
+
+	line, ok := sourceLine(source, d)
+	gutter := ""
+	if ok {
+		gutter = strings.Repeat(" ", len(strconv.Itoa(d.Pos.Line)))
+		fmt.Fprintf(w, "%s%s %s\n", gutter, paint(colorBlue, "-->"), d.Pos)
+		fmt.Fprintf(w, "%s\n", paint(colorBlue, gutter+" |"))
+		fmt.Fprintf(w, "%s %s\n", paint(colorBlue, strconv.Itoa(d.Pos.Line)+" |"), line)
+		fmt.Fprintf(w, "%s %s\n", paint(colorBlue, gutter+" |"), paint(severity, underline(source, d)))
+	} else if d.Pos.IsValid() {
+		fmt.Fprintf(w, "%s %s\n", paint(colorBlue, "-->"), d.Pos)
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "%s %s\n", paint(colorBlue, gutter+" ="), paint(colorBold, "note: ")+note)
+	}
+}
+
+// sourceLine returns the line of source d starts on.
+func sourceLine(source string, d *Diagnostic) (string, bool) {
+	if !d.Pos.IsValid() || source == "" || d.Pos.Offset > len(source) {
+		return "", false
+	}
+	start := strings.LastIndexByte(source[:d.Pos.Offset], '\n') + 1
+	end := strings.IndexByte(source[d.Pos.Offset:], '\n')
+	if end < 0 {
+		end = len(source)
+	} else {
+		end += d.Pos.Offset
+	}
+	return strings.TrimSuffix(source[start:end], "\r"), true
+}
+
+// underline returns the carets marking the span of d, after
+// blanks lining them up with the source line. Tabs are kept
+// so the alignment holds whatever the tab width. A span
+// covering several lines is underlined to the end of the
+// first one.
+func underline(source string, d *Diagnostic) string {
+	line, _ := sourceLine(source, d)
+	start := strings.LastIndexByte(source[:d.Pos.Offset], '\n') + 1
+
+	var out strings.Builder
+	for _, ch := range source[start:d.Pos.Offset] {
+		if ch == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteByte(' ')
+		}
+	}
+
+	width := 1
+	if d.End.Line == d.Pos.Line && d.End.Offset > d.Pos.Offset {
+		width = utf8.RuneCountInString(source[d.Pos.Offset:min(d.End.Offset, len(source))])
+	} else if d.End.Line > d.Pos.Line {
+		width = max(utf8.RuneCountInString(line[min(d.Pos.Offset-start, len(line)):]), 1)
+	}
+	out.WriteString(strings.Repeat("^", width))
+	return out.String()
+}
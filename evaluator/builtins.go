@@ -0,0 +1,256 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"nexus/object"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Output is where `puts` and `print` write to.
+var Output io.Writer = os.Stdout
+
+// builtins is consulted when an identifier is not bound
+// in the environment, so scripts may shadow any of them.
+var builtins = map[string]*object.Builtin{}
+
+func init() {
+	RegisterVariadicBuiltin("puts", builtinPuts)
+	RegisterVariadicBuiltin("print", builtinPrint)
+	RegisterBuiltin("len", builtinLen, object.ANY)
+	RegisterBuiltin("type", builtinType, object.ANY)
+	RegisterBuiltin("int", builtinInt, object.ANY)
+	RegisterBuiltin("float", builtinFloat, object.ANY)
+	RegisterBuiltin("first", builtinFirst, object.ARRAY)
+	RegisterBuiltin("last", builtinLast, object.ARRAY)
+	RegisterBuiltin("rest", builtinRest, object.ARRAY)
+	RegisterBuiltin("push", builtinPush, object.ARRAY, object.ANY)
+	RegisterVariadicBuiltin("range", builtinRange)
+	RegisterVariadicBuiltin("exit", builtinExit)
+}
+
+// RegisterBuiltin makes fn callable from scripts as name.
+// Before fn runs, calls are checked to pass exactly one
+// argument per entry in params, of that type (object.ANY
+// accepts any value, and object.INTEGER big integers as
+// well, so fn must handle *object.BigInt too). Registering
+// an existing name
+// replaces it. It is not safe to call concurrently with
+// Eval.
+func RegisterBuiltin(name string, fn object.BuiltinFunction, params ...object.ObjectType) {
+	builtins[name] = &object.Builtin{Name: name, Params: params, Fn: fn}
+}
+
+// RegisterVariadicBuiltin is like RegisterBuiltin, but
+// performs no argument checking: fn gets whatever the
+// script passed.
+func RegisterVariadicBuiltin(name string, fn object.BuiltinFunction) {
+	builtins[name] = &object.Builtin{Name: name, Variadic: true, Fn: fn}
+}
+
+// Builtins returns the names of all registered builtins.
+func Builtins() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	return names
+}
+
+func checkBuiltinArgs(fn *object.Builtin, args []object.Object) *object.Error {
+	if fn.Variadic {
+		return nil
+	}
+
+	if len(args) != len(fn.Params) {
+		return newError("wrong number of arguments to `%s`: want=%d, got=%d",
+			fn.Name, len(fn.Params), len(args))
+	}
+
+	for i, param := range fn.Params {
+		if param != object.ANY && typeName(args[i]) != param {
+			return newError("argument %d to `%s` must be %s, got %s",
+				i+1, fn.Name, param, typeName(args[i]))
+		}
+	}
+
+	return nil
+}
+
+// display renders a value for output: strings are
+// written as-is, everything else as in the REPL.
+func display(obj object.Object) string {
+	if str, ok := obj.(*object.String); ok {
+		return str.Value
+	}
+	return obj.Inspect()
+}
+
+func builtinPuts(args ...object.Object) object.Object {
+	for _, arg := range args {
+		fmt.Fprintln(Output, display(arg))
+	}
+	return NULL
+}
+
+func builtinPrint(args ...object.Object) object.Object {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = display(arg)
+	}
+	fmt.Fprint(Output, strings.Join(parts, " "))
+	return NULL
+}
+
+func builtinLen(args ...object.Object) object.Object {
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.Hash:
+		return &object.Integer{Value: int64(len(arg.Pairs))}
+	default:
+		return newError("argument to `len` not supported, got %s", args[0].Type())
+	}
+}
+
+func builtinType(args ...object.Object) object.Object {
+	return &object.String{Value: string(typeName(args[0]))}
+}
+
+// builtinInt converts to an integer, truncating
+// floats toward zero and parsing strings.
+func builtinInt(args ...object.Object) object.Object {
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInt:
+		return arg
+	case *object.Float:
+		if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+			return newError("cannot convert %s to INTEGER", arg.Inspect())
+		}
+		value, _ := big.NewFloat(arg.Value).Int(nil)
+		return newInteger(value)
+	case *object.String:
+		value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 0)
+		if !ok {
+			return newError("cannot convert %s to INTEGER", arg.Inspect())
+		}
+		return newInteger(value)
+	default:
+		return newError("argument to `int` not supported, got %s", args[0].Type())
+	}
+}
+
+func builtinFloat(args ...object.Object) object.Object {
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInt:
+		return &object.Float{Value: toFloat(arg)}
+	case *object.Float:
+		return arg
+	case *object.String:
+		value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+		if err != nil {
+			return newError("cannot convert %s to FLOAT", arg.Inspect())
+		}
+		return &object.Float{Value: value}
+	default:
+		return newError("argument to `float` not supported, got %s", args[0].Type())
+	}
+}
+
+func builtinFirst(args ...object.Object) object.Object {
+	elements := args[0].(*object.Array).Elements
+	if len(elements) == 0 {
+		return NULL
+	}
+	return elements[0]
+}
+
+func builtinLast(args ...object.Object) object.Object {
+	elements := args[0].(*object.Array).Elements
+	if len(elements) == 0 {
+		return NULL
+	}
+	return elements[len(elements)-1]
+}
+
+// builtinRest returns a new array holding every
+// element but the first.
+func builtinRest(args ...object.Object) object.Object {
+	elements := args[0].(*object.Array).Elements
+	if len(elements) == 0 {
+		return NULL
+	}
+	rest := make([]object.Object, len(elements)-1)
+	copy(rest, elements[1:])
+	return &object.Array{Elements: rest}
+}
+
+// builtinPush returns a new array with the value
+// appended; the original array is left untouched.
+func builtinPush(args ...object.Object) object.Object {
+	elements := args[0].(*object.Array).Elements
+	pushed := make([]object.Object, len(elements), len(elements)+1)
+	copy(pushed, elements)
+	pushed = append(pushed, args[1])
+	return &object.Array{Elements: pushed}
+}
+
+// builtinRange accepts range(end), range(start, end) and
+// range(start, end, step), like Python's.
+func builtinRange(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError("wrong number of arguments to `range`: want=1 to 3, got=%d", len(args))
+	}
+
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		if !isInteger(arg) {
+			return newError("argument %d to `range` must be INTEGER, got %s", i+1, arg.Type())
+		}
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return newError("argument %d to `range` out of range: %s", i+1, arg.Inspect())
+		}
+		bounds[i] = integer.Value
+	}
+
+	r := &object.Range{End: bounds[0], Step: 1}
+	if len(bounds) > 1 {
+		r.Start, r.End = bounds[0], bounds[1]
+	}
+	if len(bounds) > 2 {
+		r.Step = bounds[2]
+	}
+	if r.Step == 0 {
+		return newError("`range` step cannot be zero")
+	}
+
+	return r
+}
+
+// builtinExit ends the program with a status code,
+// 0 when none is given.
+func builtinExit(args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return &object.Exit{Code: 0}
+	}
+	if len(args) > 1 {
+		return newError("wrong number of arguments to `exit`: want=0 or 1, got=%d", len(args))
+	}
+
+	if !isInteger(args[0]) {
+		return newError("argument to `exit` must be INTEGER, got %s", args[0].Type())
+	}
+	code, ok := args[0].(*object.Integer)
+	if !ok || code.Value < 0 || code.Value > 255 {
+		return newError("exit code out of range: %s", args[0].Inspect())
+	}
+	return &object.Exit{Code: int(code.Value)}
+}
@@ -1,61 +1,168 @@
 package evaluator
 
 import (
+	"fmt"
+	"iter"
+	"math"
+	"math/big"
 	"nexus/ast"
 	"nexus/object"
+	"strings"
 )
 
 var (
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
-	NULL  = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
-func Eval(node ast.Node) object.Object {
+// Eval evaluates node in env. Runtime errors are tagged
+// with the position of the innermost node that raised them.
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos, err.End = node.Pos(), node.End()
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node)
+		return evalProgram(node, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression)
+		return Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInt{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBooleanObject(node.Value)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right)
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
 		return evalPrefix(node.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(node.Left)
-		right := Eval(node.Right)
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
 		return evalInfix(node.Operator, left, right)
 	case *ast.IfExpression:
-		return evalIf(node)
+		return evalIf(node, env)
 	case *ast.ReturnStatement:
-		return &object.ReturnValue{Value: Eval(node.ReturnValue)}
+		if node.ReturnValue == nil {
+			return &object.ReturnValue{Value: NULL}
+		}
+		val := Eval(node.ReturnValue, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
 	case *ast.BlockStatement:
-		return evalBlockStatement(node)
+		return evalBlockStatement(node, env)
+	case *ast.LetStatement:
+		if env.IsConst(node.Name.Value) {
+			return newError("cannot redeclare constant: %s", node.Name.Value)
+		}
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		env.Set(node.Name.Value, val)
+	case *ast.ConstStatement:
+		if env.IsConst(node.Name.Value) {
+			return newError("cannot redeclare constant: %s", node.Name.Value)
+		}
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		env.SetConst(node.Name.Value, val)
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		return applyFunction(function, args)
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case *ast.LogicalExpression:
+		return evalLogicalExpression(node, env)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	}
 	return nil
 }
 
-func evalProgram(p *ast.Program) object.Object {
+func evalProgram(p *ast.Program, env *object.Environment) object.Object {
 	var r object.Object
 
 	for _, stmt := range p.Statements {
-		r = Eval(stmt)
+		r = Eval(stmt, env)
 
-		if rv, ok := r.(*object.ReturnValue); ok {
-			return rv.Value
+		switch r := r.(type) {
+		case *object.ReturnValue:
+			return r.Value
+		case *object.Error, *object.Exit:
+			return r
+		case *object.Break, *object.Continue:
+			return newError("%s outside loop", r.Inspect())
 		}
 	}
 	return r
 }
 
-func evalStatements(stmts []ast.Statement) object.Object {
+func evalStatements(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, stmt := range stmts {
-		result = Eval(stmt)
+		result = Eval(stmt, env)
 		if rv, ok := result.(*object.ReturnValue); ok {
 			return rv.Value
 		}
@@ -64,14 +171,17 @@ func evalStatements(stmts []ast.Statement) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement) object.Object {
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var r object.Object
 
 	for _, stmt := range block.Statements {
-		r = Eval(stmt)
+		r = Eval(stmt, env)
 
-		if r != nil && r.Type() == object.RETURN {
-			return r
+		if r != nil {
+			switch r.Type() {
+			case object.RETURN, object.ERROR, object.EXIT, object.BREAK, object.CONTINUE:
+				return r
+			}
 		}
 	}
 
@@ -91,8 +201,10 @@ func evalPrefix(operator string, right object.Object) object.Object {
 		return evalNot(right)
 	case "-":
 		return evalMinus(right)
+	case "~":
+		return evalBitNot(right)
 	default:
-		return NULL
+		return newError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -110,63 +222,676 @@ func evalNot(right object.Object) object.Object {
 }
 
 func evalMinus(right object.Object) object.Object {
+	if f, ok := right.(*object.Float); ok {
+		return &object.Float{Value: -f.Value}
+	}
+	if bi, ok := right.(*object.BigInt); ok {
+		return newInteger(new(big.Int).Neg(bi.Value))
+	}
 	if right.Type() != object.INTEGER {
-		return NULL
+		return newError("unknown operator: -%s", right.Type())
 	}
 	val := right.(*object.Integer).Value
+	if val == math.MinInt64 {
+		return newInteger(new(big.Int).Neg(big.NewInt(val)))
+	}
 	return &object.Integer{Value: -val}
 }
 
+func evalBitNot(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: ^right.Value}
+	case *object.BigInt:
+		return newInteger(new(big.Int).Not(right.Value))
+	default:
+		return newError("unknown operator: ~%s", right.Type())
+	}
+}
+
+// evalInfix dispatches on the operand types. Values of
+// different types are never equal, but any other operator
+// on them is a type mismatch.
 func evalInfix(op string, left, right object.Object) object.Object {
 	switch {
-	case left.Type() == object.INTEGER && right.Type() == object.INTEGER:
+	case isInteger(left) && isInteger(right):
 		return evalIntInfix(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfix(op, left, right)
+	case left.Type() == object.STRING && right.Type() == object.STRING:
+		return evalStringInfix(op, left, right)
 	case op == "==":
 		return nativeBooleanObject(left == right)
 	case op == "!=":
 		return nativeBooleanObject(left != right)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), op, right.Type())
 	default:
-		return NULL
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
 	}
 }
 
+// evalIntInfix handles integer operands. Results that
+// overflow an int64 are redone with big integers.
 func evalIntInfix(op string, left, right object.Object) object.Object {
-	lval := left.(*object.Integer).Value
-	rval := right.(*object.Integer).Value
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if !lok || !rok {
+		return evalBigIntInfix(op, left, right)
+	}
+	lval := l.Value
+	rval := r.Value
 	result := &object.Integer{}
 
+	switch op {
+	case "+", "-", "*", "/":
+		if op == "/" && rval == 0 {
+			return newError("division by zero")
+		}
+		val, ok := checkedIntArith(op, lval, rval)
+		if !ok {
+			return evalBigIntInfix(op, left, right)
+		}
+		result.Value = val
+	case "%":
+		if rval == 0 {
+			return newError("division by zero")
+		}
+		result.Value = lval % rval
+	case "&":
+		result.Value = lval & rval
+	case "|":
+		result.Value = lval | rval
+	case "^":
+		result.Value = lval ^ rval
+	case ">>":
+		if rval < 0 {
+			return newError("negative shift count: %d", rval)
+		}
+		result.Value = lval >> rval
+	case "**", "<<":
+		// These overflow easily, so they are always
+		// computed on big integers and demoted after.
+		return evalBigIntInfix(op, left, right)
+	case "<":
+		return nativeBooleanObject(lval < rval)
+	case ">":
+		return nativeBooleanObject(lval > rval)
+	case "<=":
+		return nativeBooleanObject(lval <= rval)
+	case ">=":
+		return nativeBooleanObject(lval >= rval)
+	case "==":
+		return nativeBooleanObject(lval == rval)
+	case "!=":
+		return nativeBooleanObject(lval != rval)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
+	}
+	return result
+}
+
+func evalBigIntInfix(op string, left, right object.Object) object.Object {
+	lval := toBigInt(left)
+	rval := toBigInt(right)
+
+	switch op {
+	case "+":
+		return newInteger(new(big.Int).Add(lval, rval))
+	case "-":
+		return newInteger(new(big.Int).Sub(lval, rval))
+	case "*":
+		return newInteger(new(big.Int).Mul(lval, rval))
+	case "/":
+		if rval.Sign() == 0 {
+			return newError("division by zero")
+		}
+		// Quo truncates toward zero, like int64 division.
+		return newInteger(new(big.Int).Quo(lval, rval))
+	case "%":
+		if rval.Sign() == 0 {
+			return newError("division by zero")
+		}
+		// Rem takes the sign of the dividend, like int64 %.
+		return newInteger(new(big.Int).Rem(lval, rval))
+	case "&":
+		return newInteger(new(big.Int).And(lval, rval))
+	case "|":
+		return newInteger(new(big.Int).Or(lval, rval))
+	case "^":
+		return newInteger(new(big.Int).Xor(lval, rval))
+	case "<<", ">>":
+		if rval.Sign() < 0 {
+			return newError("negative shift count: %s", rval)
+		}
+		if op == ">>" {
+			// Shifting past the last bit gives 0 or -1, so
+			// larger counts are clamped.
+			if limit := big.NewInt(int64(lval.BitLen() + 1)); rval.Cmp(limit) > 0 {
+				rval = limit
+			}
+			return newInteger(new(big.Int).Rsh(lval, uint(rval.Uint64())))
+		}
+		if lval.Sign() != 0 && (!rval.IsInt64() || int64(lval.BitLen())+rval.Int64() > maxIntegerBits) {
+			return newError("integer too large: %s << %s", lval, rval)
+		}
+		return newInteger(new(big.Int).Lsh(lval, uint(rval.Uint64())))
+	case "**":
+		return evalIntPower(lval, rval)
+	case "<":
+		return nativeBooleanObject(lval.Cmp(rval) < 0)
+	case ">":
+		return nativeBooleanObject(lval.Cmp(rval) > 0)
+	case "<=":
+		return nativeBooleanObject(lval.Cmp(rval) <= 0)
+	case ">=":
+		return nativeBooleanObject(lval.Cmp(rval) >= 0)
+	case "==":
+		return nativeBooleanObject(lval.Cmp(rval) == 0)
+	case "!=":
+		return nativeBooleanObject(lval.Cmp(rval) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
+	}
+}
+
+// maxIntegerBits bounds the size of results of `**` and
+// `<<`, which could otherwise exhaust memory with a single
+// expression such as `2 ** 10000000000`.
+const maxIntegerBits = 1 << 24
+
+// evalIntPower raises base to exp. A negative exponent
+// gives a float, as the result is generally not integral.
+func evalIntPower(base, exp *big.Int) object.Object {
+	if exp.Sign() < 0 {
+		b, _ := new(big.Float).SetInt(base).Float64()
+		e, _ := new(big.Float).SetInt(exp).Float64()
+		return &object.Float{Value: math.Pow(b, e)}
+	}
+
+	// Bases -1, 0 and 1 do not grow whatever the exponent,
+	// so they skip the size check below.
+	if base.CmpAbs(big.NewInt(1)) <= 0 {
+		if exp.Sign() == 0 || base.Sign() < 0 && exp.Bit(0) == 0 {
+			return &object.Integer{Value: 1}
+		}
+		return newInteger(base)
+	}
+
+	if !exp.IsInt64() || exp.Int64() > maxIntegerBits ||
+		exp.Int64()*int64(base.BitLen()-1) > maxIntegerBits {
+		return newError("integer too large: %s ** %s", base, exp)
+	}
+	return newInteger(new(big.Int).Exp(base, exp, nil))
+}
+
+// newInteger wraps v as an Integer when it fits in an
+// int64 and as a BigInt otherwise.
+func newInteger(v *big.Int) object.Object {
+	if v.IsInt64() {
+		return &object.Integer{Value: v.Int64()}
+	}
+	return &object.BigInt{Value: v}
+}
+
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInt:
+		return obj.Value
+	}
+	return new(big.Int)
+}
+
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER || obj.Type() == object.BIGINT
+}
+
+// typeName is the type scripts see: big integers are
+// just integers to them.
+func typeName(obj object.Object) object.ObjectType {
+	if obj.Type() == object.BIGINT {
+		return object.INTEGER
+	}
+	return obj.Type()
+}
+
+func isNumber(obj object.Object) bool {
+	return isInteger(obj) || obj.Type() == object.FLOAT
+}
+
+// toFloat promotes a numeric object to float64.
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *object.Float:
+		return obj.Value
+	}
+	return math.NaN()
+}
+
+// evalFloatInfix handles arithmetic where at least one
+// side is a float; the other side is promoted to float.
+func evalFloatInfix(op string, left, right object.Object) object.Object {
+	lval := toFloat(left)
+	rval := toFloat(right)
+
 	switch op {
 	case "+":
-		result.Value = lval + rval
+		return &object.Float{Value: lval + rval}
 	case "-":
-		result.Value = lval - rval
+		return &object.Float{Value: lval - rval}
 	case "*":
-		result.Value = lval * rval
+		return &object.Float{Value: lval * rval}
 	case "/":
-		result.Value = lval / rval
+		if rval == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: lval / rval}
+	case "%":
+		if rval == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: math.Mod(lval, rval)}
+	case "**":
+		return &object.Float{Value: math.Pow(lval, rval)}
+	case "<":
+		return nativeBooleanObject(lval < rval)
+	case ">":
+		return nativeBooleanObject(lval > rval)
+	case "<=":
+		return nativeBooleanObject(lval <= rval)
+	case ">=":
+		return nativeBooleanObject(lval >= rval)
+	case "==":
+		return nativeBooleanObject(lval == rval)
+	case "!=":
+		return nativeBooleanObject(lval != rval)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
+	}
+}
+
+func evalStringInfix(op string, left, right object.Object) object.Object {
+	lval := left.(*object.String).Value
+	rval := right.(*object.String).Value
+
+	switch op {
+	case "+":
+		return &object.String{Value: lval + rval}
 	case "<":
 		return nativeBooleanObject(lval < rval)
 	case ">":
 		return nativeBooleanObject(lval > rval)
+	case "<=":
+		return nativeBooleanObject(lval <= rval)
+	case ">=":
+		return nativeBooleanObject(lval >= rval)
 	case "==":
 		return nativeBooleanObject(lval == rval)
 	case "!=":
 		return nativeBooleanObject(lval != rval)
 	default:
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
+	}
+}
+
+// checkedIntArith applies op to l and r, reporting false
+// when the result overflows an int64 instead of letting it
+// wrap around. r must not be zero for division.
+func checkedIntArith(op string, l, r int64) (int64, bool) {
+	var res int64
+	overflow := false
+
+	switch op {
+	case "+":
+		res = l + r
+		overflow = (r > 0 && res < l) || (r < 0 && res > l)
+	case "-":
+		res = l - r
+		overflow = (r > 0 && res > l) || (r < 0 && res < l)
+	case "*":
+		res = l * r
+		overflow = l != 0 && (res/l != r || (l == -1 && r == math.MinInt64))
+	case "/":
+		overflow = l == math.MinInt64 && r == -1
+		if !overflow {
+			res = l / r
+		}
+	}
+
+	return res, !overflow
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY && isInteger(index):
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
+
+// evalArrayIndexExpression indexes an array, counting
+// negative indices back from the end.
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	pos, err := arrayPosition(index, len(elements))
+	if err != nil {
+		return err
+	}
+
+	return elements[pos]
+}
+
+// arrayPosition resolves a possibly negative integer
+// index into a position in an array of the given length.
+// A big integer index is out of range of any array.
+func arrayPosition(index object.Object, length int) (int64, *object.Error) {
+	idx, ok := index.(*object.Integer)
+	if !ok {
+		return 0, newError("index out of range: %s (length %d)", index.Inspect(), length)
+	}
+
+	pos := idx.Value
+	if pos < 0 {
+		pos += int64(length)
+	}
+	if pos < 0 || pos >= int64(length) {
+		return 0, newError("index out of range: %d (length %d)", idx.Value, length)
+	}
+	return pos, nil
+}
+
+// evalHashIndexExpression looks up index in a hash.
+// Missing keys evaluate to NULL.
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hash.(*object.Hash).Pairs[key.HashKey()]
+	if !ok {
 		return NULL
 	}
-	return result
+
+	return pair.Value
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for _, p := range node.Pairs {
+		key := Eval(p.Key, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(p.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return &object.Hash{Pairs: pairs}
+}
+
+// evalLogicalExpression evaluates `&&` and `||`, only
+// evaluating the right side when the left side does not
+// already decide the result.
+func evalLogicalExpression(node *ast.LogicalExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	switch node.Operator {
+	case "&&":
+		if !isTruthy(left) {
+			return FALSE
+		}
+	case "||":
+		if isTruthy(left) {
+			return TRUE
+		}
+	default:
+		return newError("unknown operator: %s", node.Operator)
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBooleanObject(isTruthy(right))
+}
+
+// evalAssignExpression stores a value in the nearest
+// enclosing binding of a name, or in an array or hash
+// slot. Compound operators combine it with the current
+// value first. The result is the stored value.
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		scope := env.Scope(target.Value)
+		if scope == nil {
+			return newError("identifier not found: %s", target.Value)
+		}
+		if scope.IsConst(target.Value) {
+			return newError("cannot assign to constant: %s", target.Value)
+		}
+
+		val := evalAssignedValue(node, env, func() object.Object {
+			current, _ := scope.Get(target.Value)
+			return current
+		})
+		if isError(val) {
+			return val
+		}
+		return scope.Set(target.Value, val)
+	case *ast.IndexExpression:
+		container := Eval(target.Left, env)
+		if isError(container) {
+			return container
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		val := evalAssignedValue(node, env, func() object.Object {
+			return evalIndexExpression(container, index)
+		})
+		if isError(val) {
+			return val
+		}
+		return assignIndex(container, index, val)
+	default:
+		return newError("invalid assignment target: %s", node.Target.AsString())
+	}
+}
+
+// evalAssignedValue evaluates the right side of an
+// assignment. For compound operators such as `+=`, it
+// is combined with the value read by current.
+func evalAssignedValue(node *ast.AssignExpression, env *object.Environment, current func() object.Object) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) || node.Operator == "=" {
+		return val
+	}
+
+	left := current()
+	if isError(left) {
+		return left
+	}
+	return evalInfix(strings.TrimSuffix(node.Operator, "="), left, val)
+}
+
+func assignIndex(container, index, val object.Object) object.Object {
+	switch container := container.(type) {
+	case *object.Array:
+		if !isInteger(index) {
+			return newError("index operator not supported: %s[%s]", container.Type(), index.Type())
+		}
+		pos, err := arrayPosition(index, len(container.Elements))
+		if err != nil {
+			return err
+		}
+		container.Elements[pos] = val
+		return val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		container.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: val}
+		return val
+	default:
+		return newError("index assignment not supported: %s", container.Type())
+	}
+}
+
+// evalWhileStatement runs the body in a fresh scope each
+// time, like evalForStatement, so its declarations do not
+// clash with the previous iteration's.
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		cond := Eval(ws.Condition, env)
+		if isError(cond) {
+			return cond
+		}
+		if !isTruthy(cond) {
+			return nil
+		}
+
+		if r, stop := loopControl(Eval(ws.Body, object.NewEnclosedEnvironment(env))); stop {
+			return r
+		}
+	}
+}
+
+// evalForStatement runs the body once per item, each time
+// in a fresh scope holding the loop variable, so closures
+// created in the body capture that iteration's value.
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	items, err := iterate(iterable)
+	if err != nil {
+		return err
+	}
+
+	for item := range items {
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(fs.Variable.Value, item)
+
+		if r, stop := loopControl(Eval(fs.Body, loopEnv)); stop {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// loopControl inspects the result of a loop body and
+// reports whether the loop has to stop, and with what
+// result: nothing for a break, the signal itself for a
+// return or an error.
+func loopControl(r object.Object) (object.Object, bool) {
+	switch r.(type) {
+	case *object.Break:
+		return nil, true
+	case *object.ReturnValue, *object.Error, *object.Exit:
+		return r, true
+	}
+	return nil, false
+}
+
+// iterate yields the items a for loop visits: the elements
+// of an array, the characters of a string, the keys of a
+// hash or the integers of a range.
+func iterate(obj object.Object) (iter.Seq[object.Object], *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		elements := obj.Elements
+		return func(yield func(object.Object) bool) {
+			for _, el := range elements {
+				if !yield(el) {
+					return
+				}
+			}
+		}, nil
+	case *object.String:
+		return func(yield func(object.Object) bool) {
+			for _, ch := range obj.Value {
+				if !yield(&object.String{Value: string(ch)}) {
+					return
+				}
+			}
+		}, nil
+	case *object.Hash:
+		pairs := obj.SortedPairs()
+		return func(yield func(object.Object) bool) {
+			for _, pair := range pairs {
+				if !yield(pair.Key) {
+					return
+				}
+			}
+		}, nil
+	case *object.Range:
+		return func(yield func(object.Object) bool) {
+			for i := obj.Start; obj.Step > 0 && i < obj.End || obj.Step < 0 && i > obj.End; {
+				if !yield(&object.Integer{Value: i}) {
+					return
+				}
+				next := i + obj.Step
+				if (next > i) != (obj.Step > 0) {
+					return // the next value would overflow
+				}
+				i = next
+			}
+		}, nil
+	default:
+		return nil, newError("cannot iterate over %s", obj.Type())
+	}
 }
 
-func evalIf(ie *ast.IfExpression) object.Object {
-	cond := Eval(ie.Condition)
+// evalIf yields the value of the branch taken. A branch
+// that yields nothing (e.g. an empty one, or one ending
+// in a let or a loop) evaluates to NULL, like a body.
+func evalIf(ie *ast.IfExpression, env *object.Environment) object.Object {
+	cond := Eval(ie.Condition, env)
+	if isError(cond) {
+		return cond
+	}
+
+	var r object.Object
 	if isTruthy(cond) {
-		return Eval(ie.Consequence)
+		r = Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative)
-	} else {
+		r = Eval(ie.Alternative, env)
+	}
+	if r == nil {
 		return NULL
 	}
+	return r
 }
 
 func isTruthy(obj object.Object) bool {
@@ -181,3 +906,88 @@ func isTruthy(obj object.Object) bool {
 		return true
 	}
 }
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+	if builtin, ok := builtins[node.Value]; ok {
+		return builtin
+	}
+	return newError("identifier not found: %s", node.Value)
+}
+
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		ev := Eval(e, env)
+		if isError(ev) {
+			return []object.Object{ev}
+		}
+		result = append(result, ev)
+	}
+
+	return result
+}
+
+func applyFunction(fn object.Object, args []object.Object) object.Object {
+	switch function := fn.(type) {
+	case *object.Function:
+		if len(args) != len(function.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d",
+				len(function.Parameters), len(args))
+		}
+
+		env := extendFunctionEnv(function, args)
+		ev := Eval(function.Body, env)
+		return unwrapReturnValue(ev)
+	case *object.Builtin:
+		if err := checkBuiltinArgs(function, args); err != nil {
+			return err
+		}
+		if result := function.Fn(args...); result != nil {
+			return result
+		}
+		return NULL
+	default:
+		return newError("not a function: %s", fn.Type())
+	}
+}
+
+// extendFunctionEnv binds the call arguments to the
+// parameters in a scope enclosed by the function's own.
+func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+	env := object.NewEnclosedEnvironment(fn.Env)
+
+	for i, param := range fn.Parameters {
+		env.Set(param.Value, args[i])
+	}
+
+	return env
+}
+
+// unwrapReturnValue stops a return from bubbling
+// past the call that produced it. A body that yields
+// nothing (e.g. an empty one) evaluates to NULL.
+func unwrapReturnValue(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.ReturnValue:
+		return obj.Value
+	case *object.Break, *object.Continue:
+		return newError("%s outside loop", obj.Inspect())
+	case nil:
+		return NULL
+	}
+	return obj
+}
+
+func newError(format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// isError reports whether obj stops evaluation: an error,
+// or an exit, which unwinds the same way.
+func isError(obj object.Object) bool {
+	return obj != nil && (obj.Type() == object.ERROR || obj.Type() == object.EXIT)
+}
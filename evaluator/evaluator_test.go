@@ -1,9 +1,12 @@
 package evaluator
 
 import (
+	"bytes"
+	"nexus/diagnostic"
 	"nexus/lexer"
 	"nexus/object"
 	"nexus/parser"
+	"os"
 	"testing"
 )
 
@@ -27,6 +30,9 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"9223372036854775806 + 1", 9223372036854775807},
+		{"-9223372036854775807 - 1", -9223372036854775808},
+		{"-7 / 2", -3},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -38,7 +44,8 @@ func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
-	return Eval(program)
+	env := object.NewEnvironment()
+	return Eval(program, env)
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
@@ -79,6 +86,13 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{`"a" == 1`, false},
+		{`"a" != 1`, true},
+		{"1 == true", false},
+		{"[1] != 1", true},
+		{"first([]) == 1", false},
+		{"first([]) != 1", true},
+		{"first([]) == last([])", true},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -143,6 +157,22 @@ func TestIfElseExpressions(t *testing.T) {
 	}
 }
 
+func TestEmptyBranches(t *testing.T) {
+	testNullObject(t, testEval("let a = if (true) { }; a"))
+	testNullObject(t, testEval("if (false) { 1 } else { }"))
+	testNullObject(t, testEval("if (true) { let b = 1 }"))
+
+	if got := testEval("[if (true) {}]").Inspect(); got != "[null]" {
+		t.Errorf("wrong array. got=%s", got)
+	}
+
+	for _, input := range []string{"-if (true) {}", "let a = if (true) { while (false) {} }; a + 1"} {
+		if _, ok := testEval(input).(*object.Error); !ok {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != NULL {
 		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
@@ -173,3 +203,896 @@ func TestReturn(t *testing.T) {
 		testIntegerObject(t, ev, tt.exp)
 	}
 }
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		exp   int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let x = 5; x * 2;", 10},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.exp)
+	}
+}
+
+func TestUnknownIdentifier(t *testing.T) {
+	ev := testEval("let a = 5; b;")
+
+	err, ok := ev.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", ev, ev)
+	}
+	if err.Message != "identifier not found: b" {
+		t.Errorf("wrong error message. got=%q", err.Message)
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	ev := testEval("fn(x) { x + 2; };")
+
+	fn, ok := ev.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", ev, ev)
+	}
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. got=%+v", fn.Parameters)
+	}
+	if fn.Parameters[0].AsString() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	}
+	if fn.Body.AsString() != "(x + 2)" {
+		t.Fatalf("body is not %q. got=%q", "(x + 2)", fn.Body.AsString())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input string
+		exp   int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let f = fn() { return 1; 2; }; f() + 10;", 11},
+		{"let twice = fn(f, x) { f(f(x)); }; twice(fn(x) { x * 3; }, 2);", 18},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.exp)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+	let newAdder = fn(x) {
+		fn(y) { x + y };
+	};
+	let addTwo = newAdder(2);
+	addTwo(2);`
+
+	testIntegerObject(t, testEval(input), 4)
+}
+
+func TestFunctionArity(t *testing.T) {
+	ev := testEval("let add = fn(x, y) { x + y; }; add(1);")
+
+	err, ok := ev.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", ev, ev)
+	}
+	if err.Message != "wrong number of arguments: want=2, got=1" {
+		t.Errorf("wrong error message. got=%q", err.Message)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input string
+		exp   string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{`if (10 > 1) {
+			if (10 > 1) {
+				return true + false;
+			}
+			return 1;
+		}`, "unknown operator: BOOLEAN + BOOLEAN"},
+		{"-(5 + true) + 1", "type mismatch: INTEGER + BOOLEAN"},
+		{"if (-true) { 1 }", "unknown operator: -BOOLEAN"},
+		{"let f = fn(x) { x; }; f(1, -true);", "unknown operator: -BOOLEAN"},
+		{"let x = 1; x(2);", "not a function: INTEGER"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{`"a" + 1`, "type mismatch: STRING + INTEGER"},
+		{"[1, 2, 3][3]", "index out of range: 3 (length 3)"},
+		{"[1, 2, 3][-4]", "index out of range: -4 (length 3)"},
+		{"[][0]", "index out of range: 0 (length 0)"},
+		{"1[0]", "index operator not supported: INTEGER[INTEGER]"},
+		{"[1][true]", "index operator not supported: ARRAY[BOOLEAN]"},
+		{"[1, -true]", "unknown operator: -BOOLEAN"},
+		{`{"name": "Nexus"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{fn(x) { x }: 1};`, "unusable as hash key: FUNCTION"},
+		{`{[1]: 1};`, "unusable as hash key: ARRAY"},
+		{"1.5 / 0", "division by zero"},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{`int("abc")`, `cannot convert "abc" to INTEGER`},
+		{`int(float("inf"))`, "cannot convert +Inf to INTEGER"},
+		{`float(true)`, "argument to `float` not supported, got BOOLEAN"},
+		{"for x in 5 { x }", "cannot iterate over INTEGER"},
+		{"for x in [1, 2] { x + true }", "type mismatch: INTEGER + BOOLEAN"},
+		{"while (-true) { 1 }", "unknown operator: -BOOLEAN"},
+		{"range(1, 2, 0)", "`range` step cannot be zero"},
+		{`range("a")`, "argument 1 to `range` must be INTEGER, got STRING"},
+		{"range(2 ** 64)", "argument 1 to `range` out of range: 18446744073709551616"},
+		{"[1][2 ** 64]", "index out of range: 18446744073709551616 (length 1)"},
+		{"[1][-(2 ** 64)]", "index out of range: -18446744073709551616 (length 1)"},
+		{"let a = [1]; a[2 ** 64] = 2", "index out of range: 18446744073709551616 (length 1)"},
+		{"exit(2 ** 64)", "exit code out of range: 18446744073709551616"},
+		{"x = 1", "identifier not found: x"},
+		{"let f = fn() { y += 1 }; f()", "identifier not found: y"},
+		{"const c = 1; c = 2", "cannot assign to constant: c"},
+		{"const c = 1; let f = fn() { c += 1 }; f()", "cannot assign to constant: c"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1 (length 1)"},
+		{`let a = [1]; a["x"] = 2`, "index operator not supported: ARRAY[STRING]"},
+		{`let h = {}; h[[1]] = 2`, "unusable as hash key: ARRAY"},
+		{`let s = "abc"; s[0] = "x"`, "index assignment not supported: STRING"},
+		{`let x = 1; x += "a"`, "type mismatch: INTEGER + STRING"},
+		{"true && -true", "unknown operator: -BOOLEAN"},
+		{"-true || true", "unknown operator: -BOOLEAN"},
+		{"5 % 0", "division by zero"},
+		{"5.5 % 0", "division by zero"},
+		{"100000000000000000000 % 0", "division by zero"},
+		{"1 << -1", "negative shift count: -1"},
+		{"1 >> -1", "negative shift count: -1"},
+		{"100000000000000000000 >> -2", "negative shift count: -2"},
+		{"~true", "unknown operator: ~BOOLEAN"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{`"a" % "b"`, "unknown operator: STRING % STRING"},
+		{"2 ** 100000000", "integer too large: 2 ** 100000000"},
+		{"1 << 100000000", "integer too large: 1 << 100000000"},
+		{"let a = -true; 5;", "unknown operator: -BOOLEAN"},
+		{"const a = 1; const a = 2;", "cannot redeclare constant: a"},
+		{"const a = 1; let a = 2;", "cannot redeclare constant: a"},
+		{"1 / 0", "division by zero"},
+		{"let f = fn(x) { 10 / x }; f(0);", "division by zero"},
+		{"100000000000000000000 / 0", "division by zero"},
+	}
+
+	for _, tt := range tests {
+		ev := testEval(tt.input)
+
+		err, ok := ev.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, ev, ev)
+			continue
+		}
+		if err.Message != tt.exp {
+			t.Errorf("wrong error message. want=%q, got=%q", tt.exp, err.Message)
+		}
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		exp   int64
+	}{
+		{"const LIMIT = 10; LIMIT;", 10},
+		{"const a = 5; let b = a * 2; b;", 10},
+		{"const a = 5; let f = fn() { let a = 1; a; }; f() + a;", 6},
+		{"const a = 5; let f = fn(a) { a; }; f(2);", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.exp)
+	}
+}
+
+func TestBigIntPromotion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"9223372036854775807 * 2", "18446744073709551614"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) * -1", "9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"-123456789012345678901234567890", "-123456789012345678901234567890"},
+		{"100000000000000000000 / -7", "-14285714285714285714"},
+		{`int("99999999999999999999")`, "99999999999999999999"},
+		{`let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)`,
+			"15511210043330985984000000"},
+	}
+
+	for _, tt := range tests {
+		ev := testEval(tt.input)
+		result, ok := ev.(*object.BigInt)
+		if !ok {
+			t.Errorf("object is not BigInt for %q. got=%T (%+v)", tt.input, ev, ev)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong value for %q. want=%s, got=%s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	// Results that fit in an int64 again are demoted.
+	integers := []struct {
+		input    string
+		expected int64
+	}{
+		{"9223372036854775807 + 1 - 1", 9223372036854775807},
+		{"100000000000000000000 / 100000000000000000000", 1},
+		{"100000000000000000000 - 100000000000000000000", 0},
+		{"-(-9223372036854775807 - 1) - 1", 9223372036854775807},
+	}
+
+	for _, tt := range integers {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	booleans := []struct {
+		input    string
+		expected bool
+	}{
+		{"100000000000000000000 > 1", true},
+		{"100000000000000000000 == 100000000000000000000", true},
+		{"100000000000000000000 != 100000000000000000001", true},
+		{"100000000000000000000 == 1e20", true},
+		{`{100000000000000000000: true}[100000000000000000000]`, true},
+	}
+
+	for _, tt := range booleans {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	ev := testEval(`"Hello World!"`)
+
+	str, ok := ev.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", ev, ev)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+	if str.Inspect() != `"Hello World!"` {
+		t.Errorf("String has wrong representation. got=%s", str.Inspect())
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	ev := testEval(`let greet = fn(name) { "Hello" + " " + name + "!" }; greet("World");`)
+
+	str, ok := ev.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", ev, ev)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" < "b"`, true},
+		{`"b" > "a"`, true},
+		{`"abc" < "abd"`, true},
+		{`"a" + "b" == "ab"`, true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	ev := testEval("[1, 2 * 2, 3 + 3]")
+
+	result, ok := ev.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", ev, ev)
+	}
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		exp   int64
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[[1, 2], [3, 4]][1][0]", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.exp)
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	ev := testEval(input)
+	result, ok := ev.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", ev, ev)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"name": "x", 1: 2}[1]`, 2},
+	}
+
+	for _, tt := range tests {
+		ev := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, ev, int64(integer))
+		} else {
+			testNullObject(t, ev)
+		}
+	}
+}
+
+func TestHashInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 2, "a": [1], 3: true}`, `{3: true, "a": [1], "b": 2}`},
+		{`{10: "b", 2: "a", -1: "c"}`, `{-1: "c", 2: "a", 10: "b"}`},
+		{`{2 ** 64: 1, 5: 2, -(2 ** 64): 3}`, `{-18446744073709551616: 3, 5: 2, 18446744073709551616: 1}`},
+		{`{"x": 1, true: 2, false: 3}`, `{false: 3, true: 2, "x": 1}`},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong hash representation. expected=%s, got=%s", tt.expected, got)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len([1, 2, 3])`, 3},
+		{`len({"a": 1})`, 1},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to `len`: want=1, got=2"},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument 1 to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`rest([1, 2, 3])[0]`, 2},
+		{`len(rest([1, 2, 3]))`, 2},
+		{`rest([])`, nil},
+		{`push([], 1)[0]`, 1},
+		{`let a = [1]; push(a, 2); len(a)`, 1},
+		{`push(1, 1)`, "argument 1 to `push` must be ARRAY, got INTEGER"},
+		{`type(1)`, "INTEGER"},
+		{`type(2 ** 100)`, "INTEGER"},
+		{`type("a")`, "STRING"},
+		{`type(len)`, "BUILTIN"},
+		{`let len = fn(x) { 42 }; len([1])`, 42},
+	}
+
+	for _, tt := range tests {
+		ev := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, ev, int64(expected))
+		case nil:
+			testNullObject(t, ev)
+		case string:
+			switch obj := ev.(type) {
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("wrong string. expected=%q, got=%q", expected, obj.Value)
+				}
+			default:
+				t.Errorf("object is not Error or String. got=%T (%+v)", ev, ev)
+			}
+		}
+	}
+}
+
+func TestBuiltinOutput(t *testing.T) {
+	var out bytes.Buffer
+	Output = &out
+	defer func() { Output = os.Stdout }()
+
+	testNullObject(t, testEval(`puts("hi", 1, [true]); print("a", "b"); print("c")`))
+
+	expected := "hi\n1\n[true]\na bc"
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	RegisterBuiltin("double", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	}, object.INTEGER)
+	defer delete(builtins, "double")
+
+	testIntegerObject(t, testEval("double(21)"), 42)
+
+	RegisterBuiltin("sign", func(args ...object.Object) object.Object {
+		return &object.String{Value: args[0].Inspect()[:1]}
+	}, object.INTEGER)
+	defer delete(builtins, "sign")
+
+	if str, ok := testEval("sign(-(2 ** 64))").(*object.String); !ok || str.Value != "-" {
+		t.Errorf("big integer not accepted as INTEGER. got=%+v", str)
+	}
+
+	ev := testEval(`double("x")`)
+	err, ok := ev.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", ev, ev)
+	}
+	if err.Message != "argument 1 to `double` must be INTEGER, got STRING" {
+		t.Errorf("wrong error message. got=%q", err.Message)
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{".5", 0.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+		{"1e3 - 1", 999},
+		{"let avg = fn(a, b) { (a + b) / 2.0 }; avg(3, 4)", 3.5},
+		{"float(3)", 3},
+		{`float("2.25")`, 2.25},
+	}
+
+	for _, tt := range tests {
+		testFloatObject(t, testEval(tt.input), tt.expected)
+	}
+
+	booleans := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 == 1.0", true},
+		{"0.1 + 0.2 == 0.3", false},
+		{"1.5 > 1", true},
+		{"2 < 1.5", false},
+		{"2.0 != 2", false},
+	}
+
+	for _, tt := range booleans {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+
+	integers := []struct {
+		input    string
+		expected int64
+	}{
+		{"int(3.99)", 3},
+		{"int(-3.99)", -3},
+		{`int("42")`, 42},
+		{"int(7)", 7},
+		{"7 / 2", 3},
+	}
+
+	for _, tt := range integers {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.0", "2.0"},
+		{"1 / 4.0", "0.25"},
+		{"float(10)", "10.0"},
+		{"1e21", "1e+21"},
+		{"-0.5", "-0.5"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong representation for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// testOutput evaluates input and returns what it wrote
+// with `puts` and `print`, along with its result.
+func testOutput(input string) (string, object.Object) {
+	var out bytes.Buffer
+	Output = &out
+	defer func() { Output = os.Stdout }()
+
+	ev := testEval(input)
+	return out.String(), ev
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`for (x in [1, 2, 3]) { print(x) }`, "123"},
+		{`for x in [1, 2, 3] { print(x) }`, "123"},
+		{`for c in "añb" { print(c, "") }`, "a ñ b "},
+		{`for k in {"b": 1, "a": 2} { print(k) }`, "ab"},
+		{`for k in {10: 1, 2: 2} { print(k, "") }`, "2 10 "},
+		{`for i in range(3) { print(i) }`, "012"},
+		{`for i in range(2, 5) { print(i) }`, "234"},
+		{`for i in range(5, 0, -2) { print(i) }`, "531"},
+		{`for i in range(9223372036854775806, 9223372036854775807, 5) { print(i) }`, "9223372036854775806"},
+		{`for i in range(5) { if (i == 3) { break; } print(i) }`, "012"},
+		{`for i in range(5) { if (i == 1) { continue; } print(i) }`, "0234"},
+		{`for i in range(3) { for j in range(3) { if (j == 1) { break } print(i, j, "") } }`, "0 0 1 0 2 0 "},
+		{`for x in [] { print(x) }`, ""},
+		{`while (false) { print(1) }`, ""},
+		{`while (true) { print(1); break; print(2) }`, "1"},
+		{`let f = fn(n) { if (n > 0) { print(n); f(n - 1) } }; while (true) { f(3); break }`, "321"},
+		{`let i = 0; while (i < 3) { const x = i; print(x); i += 1 }`, "012"},
+	}
+
+	for _, tt := range tests {
+		out, ev := testOutput(tt.input)
+		if isError(ev) {
+			t.Errorf("unexpected error for %q: %s", tt.input, ev.Inspect())
+			continue
+		}
+		if out != tt.expected {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestLoopReturn(t *testing.T) {
+	tests := []struct {
+		input string
+		exp   int64
+	}{
+		{"let find = fn(xs, y) { for x in xs { if (x == y) { return x * 10; } } return -1; }; find([1, 2, 3], 2)", 20},
+		{"let find = fn(xs, y) { for x in xs { if (x == y) { return x * 10; } } return -1; }; find([1, 2, 3], 5)", -1},
+		{"let f = fn() { while (true) { return 7; } }; f()", 7},
+		{"let mk = fn(xs) { for x in xs { return fn() { x }; } }; mk([4, 5])()", 4},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.exp)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		exp   int64
+	}{
+		{"let x = 1; x = x + 1; x", 2},
+		{"let x = 1; x = 5", 5},
+		{"let x = 1; x += 4; x", 5},
+		{"let x = 10; x -= 4; x", 6},
+		{"let x = 3; x *= 4; x", 12},
+		{"let x = 12; x /= 4; x", 3},
+		{"let x = 1; let y = 1; x = y = 7; x + y", 14},
+		{"let x = 1; let f = fn() { x = 10 }; f(); x", 10},
+		{"let x = 1; let f = fn() { let x = 2; x = 10 }; f(); x", 1},
+		{"let sum = 0; for i in range(5) { sum += i } sum", 10},
+		{"let i = 0; while (i < 10) { i += 1 } i", 10},
+		{"let newCounter = fn() { let n = 0; fn() { n += 1 } }; let c = newCounter(); c(); c(); c()", 3},
+		{"let a = [1, 2, 3]; a[0] = 10; a[0]", 10},
+		{"let a = [1, 2, 3]; a[-1] += 5; a[2]", 8},
+		{"let a = [1, 2, 3]; let b = a; b[1] = 20; a[1]", 20},
+		{`let m = {"k": 1}; m["k"] = 5; m["k"]`, 5},
+		{`let m = {}; m["new"] = 3; m["new"] + len(m)`, 4},
+		{`let m = {"n": 1}; m["n"] += 1; m["n"]`, 2},
+		{`let m = {"a": [0]}; m["a"][0] = 9; m["a"][0]`, 9},
+		{"const a = [1]; a[0] = 2; a[0]", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.exp)
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 0", true},
+		{"if (false) { 1 } || true", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"false && true || true", true},
+		{"true || false && false", true},
+		{"false && -true", false},
+		{"true || undefinedName", true},
+		{"let a = [1]; len(a) > 0 && a[0] == 1", true},
+		{"let a = []; len(a) > 0 && a[0] == 1", false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+
+	out, _ := testOutput(`let f = fn(x) { print(x); x }; f(false) && f(true); f(true) || f(false);`)
+	if out != "falsetrue" {
+		t.Errorf("right side evaluated when it should not be. output=%q", out)
+	}
+}
+
+func TestOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"7 % 3", 7 % 3},
+		{"-7 % 3", -7 % 3},
+		{"7 % -3", 7 % -3},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"(-2) ** 3", -8},
+		{"0 ** 0", 1},
+		{"(-1) ** 1000001", -1},
+		{"1 ** 100000000000", 1},
+		{"6 & 3", 6 & 3},
+		{"6 | 3", 6 | 3},
+		{"6 ^ 3", 6 ^ 3},
+		{"~5", ^5},
+		{"~-1", 0},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"1 >> 64", 0},
+		{"-1 >> 100", -1},
+		{"1 + 2 << 3", 24},
+		{"5 & 3 == 1", true},
+		{"(2 ** 64) >> 60", 16},
+		{"(2 ** 64 + 5) % 8", 5},
+		{"(2 ** 64 + 1) & 3", 1},
+		{"~(2 ** 64) + 2 ** 64", -1},
+		{"let x = 7; x % 2 == 1 && x > 0", true},
+		{"1 <= 1", true},
+		{"1 <= 0", false},
+		{"1 >= 1", true},
+		{"0 >= 1", false},
+		{"1.5 <= 1.5", true},
+		{"2 >= 2.5", false},
+		{`"a" <= "a"`, true},
+		{`"b" >= "c"`, false},
+		{"2 ** 64 >= 2 ** 63", true},
+		{"7.5 % 2", 1.5},
+		{"2.0 ** 0.5 * 2.0 ** 0.5 > 1.99", true},
+		{"2 ** -1", 0.5},
+	}
+
+	for _, tt := range tests {
+		ev := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, ev, int64(expected))
+		case float64:
+			testFloatObject(t, ev, expected)
+		case bool:
+			testBooleanObject(t, ev, expected)
+		}
+	}
+
+	big := []struct {
+		input    string
+		expected string
+	}{
+		{"2 ** 64", "18446744073709551616"},
+		{"1 << 70", "1180591620717411303424"},
+		{"3 ** 50", "717897987691852588770249"},
+		{"(2 ** 64) << 1", "36893488147419103232"},
+		{"(2 ** 64) | 1", "18446744073709551617"},
+	}
+
+	for _, tt := range big {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 1;\nb", "2:1: identifier not found: b"},
+		{"let a = 1;\nlet b = a + true;", "2:9: type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn(x) {\n  x / 0\n};\nf(1)", "2:3: division by zero"},
+		{"if (true) {\n  5 + -true;\n}", "2:7: unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errObj.Error())
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `
+let año = 2024;
+let 名前 = "日本語";
+año + len(名前)`
+
+	testIntegerObject(t, testEval(input), 2027)
+}
+
+func TestBareReturn(t *testing.T) {
+	testNullObject(t, testEval("let f = fn() { return; 5 }; f()"))
+	testNullObject(t, testEval("let f = fn(x) { if (x) { return } x }; f(true)"))
+}
+
+func TestErrorDiagnostic(t *testing.T) {
+	errObj, ok := testEval("let x = 1;\nx + true").(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+
+	d := errObj.Diagnostic()
+	if d.Code != diagnostic.RuntimeError || d.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong diagnostic. got=%s %q", d.Code, d.Message)
+	}
+	if d.Pos.String() != "2:1" || d.End.String() != "2:9" {
+		t.Errorf("wrong span. got=%s-%s", d.Pos, d.End)
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"exit(); 5", 0},
+		{"exit(3); 5", 3},
+		{"let f = fn() { exit(4) }; f(); 5", 4},
+		{"for x in [1, 2] { while (true) { exit(x + 6) } }; 5", 7},
+		{"puts(exit(1)); 5", 1},
+	}
+
+	for _, tt := range tests {
+		exit, ok := testEval(tt.input).(*object.Exit)
+		if !ok {
+			t.Errorf("%q: no exit returned", tt.input)
+			continue
+		}
+		if exit.Code != tt.expected {
+			t.Errorf("%q: wrong code. expected=%d, got=%d", tt.input, tt.expected, exit.Code)
+		}
+	}
+
+	for _, input := range []string{`exit("a")`, "exit(256)", "exit(-1)", "exit(1, 2)"} {
+		if _, ok := testEval(input).(*object.Error); !ok {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+// Package format prints Nexus programs in a canonical
+// layout, keeping their comments.
+package format
+
+import (
+	"math"
+	"nexus/ast"
+	"nexus/diagnostic"
+	"nexus/lexer"
+	"nexus/parser"
+	"nexus/token"
+	"strings"
+)
+
+// indent is one level of indentation.
+const indent = "    "
+
+// atom is the precedence of expressions that never need
+// parentheses, such as literals and identifiers.
+const atom = parser.INDEX + 1
+
+// Source formats src. Statements go one per line, blocks
+// are indented, and parentheses are only kept where the
+// grouping needs them. Comments are kept, at the statement
+// boundary that follows them, and so is a single blank line
+// between statements. Programs with syntax errors are not
+// formatted; their problems are returned instead.
+func Source(filename, src string) (string, []*diagnostic.Diagnostic) {
+	lex := lexer.NewFile(filename, src)
+	par := parser.New(lex)
+	prog := par.ParseProgram()
+	if err := par.Errors(); len(err) > 0 {
+		return "", err
+	}
+
+	p := &printer{src: src, comments: lex.Comments()}
+	out := p.statements(prog.Statements, 0, math.MaxInt, 0)
+	return strings.TrimLeft(out, "\n"), nil
+}
+
+type printer struct {
+	src      string
+	comments []token.Comment // The ones not printed yet
+}
+
+// statements prints a list of statements one per line at
+// the given depth, along with the comments found between
+// the start and end offsets. The first statement starts on
+// line first of the source.
+func (p *printer) statements(stmts []ast.Statement, first, end, depth int) string {
+	var out strings.Builder
+	prefix := strings.Repeat(indent, depth)
+	line := first // Source line printed last
+
+	// blank keeps one empty line where the source had some
+	blank := func(pos token.Position) {
+		if line > 0 && out.Len() > 0 && pos.Line > line+1 {
+			out.WriteString("\n")
+		}
+	}
+
+	leading := func(offset int) {
+		for len(p.comments) > 0 && p.comments[0].Pos.Offset < offset {
+			c := p.comments[0]
+			p.comments = p.comments[1:]
+			blank(c.Pos)
+			out.WriteString(prefix + c.Text + "\n")
+			line = c.End.Line
+		}
+	}
+
+	for i, stmt := range stmts {
+		leading(stmt.Pos().Offset)
+		blank(stmt.Pos())
+
+		next := end
+		var following ast.Statement
+		if i+1 < len(stmts) {
+			following = stmts[i+1]
+			next = following.Pos().Offset
+		}
+
+		out.WriteString(prefix + p.statement(stmt, depth))
+		if p.needsSemicolon(stmt, following, i == len(stmts)-1 && depth > 0, depth) {
+			out.WriteString(";")
+		}
+		line = stmt.End().Line
+
+		// Comments left inside the statement, or after it
+		// on the same line, are written at its end
+		afterLine := false
+		for len(p.comments) > 0 {
+			c := p.comments[0]
+			inside := c.Pos.Offset < stmt.End().Offset
+			sameLine := c.Pos.Line == line && c.Pos.Offset < next
+			if !inside && !sameLine {
+				break
+			}
+			p.comments = p.comments[1:]
+			if afterLine {
+				out.WriteString("\n" + prefix + c.Text)
+			} else {
+				out.WriteString(" " + c.Text)
+			}
+			afterLine = strings.HasPrefix(c.Text, "//")
+			line = max(line, c.End.Line)
+		}
+		out.WriteString("\n")
+	}
+
+	leading(end)
+	return out.String()
+}
+
+// needsSemicolon reports whether stmt is written with a
+// closing `;`. Declarations, returns and loop controls
+// always are. Expression statements are too, except the
+// last one of a block and `if` expressions, unless what
+// follows would read as a continuation of the `if`.
+func (p *printer) needsSemicolon(stmt, following ast.Statement, last bool, depth int) bool {
+	switch stmt := stmt.(type) {
+	case *ast.WhileStatement, *ast.ForStatement:
+		return false
+	case *ast.ExpressionStatement:
+		if last {
+			return false
+		}
+		if _, ok := stmt.Expression.(*ast.IfExpression); ok {
+			if following == nil {
+				return false
+			}
+			// Printed without comments, so that they are
+			// left for when following is printed for real
+			probe := &printer{src: p.src}
+			return continues(probe.statement(following, depth))
+		}
+	}
+	return true
+}
+
+// continues reports whether text, written right after an
+// expression, would be read as part of it: a call, an
+// index or a subtraction.
+func continues(text string) bool {
+	return strings.HasPrefix(text, "(") || strings.HasPrefix(text, "[") || strings.HasPrefix(text, "-")
+}
+
+// statement prints stmt without its closing `;`.
+func (p *printer) statement(stmt ast.Statement, depth int) string {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return "let " + stmt.Name.Value + " = " + p.expression(stmt.Value, depth)
+	case *ast.ConstStatement:
+		return "const " + stmt.Name.Value + " = " + p.expression(stmt.Value, depth)
+	case *ast.ReturnStatement:
+		if stmt.ReturnValue == nil {
+			return "return"
+		}
+		return "return " + p.expression(stmt.ReturnValue, depth)
+	case *ast.BreakStatement:
+		return "break"
+	case *ast.ContinueStatement:
+		return "continue"
+	case *ast.WhileStatement:
+		return "while (" + p.expression(stmt.Condition, depth) + ") " + p.block(stmt.Body, depth)
+	case *ast.ForStatement:
+		return "for " + stmt.Variable.Value + " in " + p.expression(stmt.Iterable, depth) + " " + p.block(stmt.Body, depth)
+	case *ast.ExpressionStatement:
+		return p.expression(stmt.Expression, depth)
+	case *ast.BlockStatement:
+		return p.block(stmt, depth)
+	}
+	return stmt.AsString()
+}
+
+// block prints a braced block whose `{` is on a line at
+// the given depth. Blocks written on one line without
+// comments stay on one line.
+func (p *printer) block(b *ast.BlockStatement, depth int) string {
+	hasComments := len(p.comments) > 0 && p.comments[0].Pos.Offset < b.Close.Pos.Offset
+	if !hasComments && b.Token.Pos.Line == b.Close.Pos.Line {
+		if len(b.Statements) == 0 {
+			return "{}"
+		}
+		if inline, ok := p.inlineBlock(b, depth); ok {
+			return inline
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("{")
+
+	// Comments right after the `{` stay there
+	first := b.Token.Pos.Line
+	for len(p.comments) > 0 && p.comments[0].Pos.Line == first && p.comments[0].Pos.Offset < b.Close.Pos.Offset {
+		c := p.comments[0]
+		if len(b.Statements) > 0 && c.Pos.Offset > b.Statements[0].Pos().Offset {
+			break
+		}
+		p.comments = p.comments[1:]
+		out.WriteString(" " + c.Text)
+		first = c.End.Line
+		if strings.HasPrefix(c.Text, "//") {
+			break
+		}
+	}
+
+	out.WriteString("\n")
+	out.WriteString(p.statements(b.Statements, first, b.Close.Pos.Offset, depth+1))
+	out.WriteString(strings.Repeat(indent, depth) + "}")
+	return out.String()
+}
+
+// inlineBlock prints b as `{ a; b }`, if each of its
+// statements fits on one line.
+func (p *printer) inlineBlock(b *ast.BlockStatement, depth int) (string, bool) {
+	parts := make([]string, len(b.Statements))
+	for i, stmt := range b.Statements {
+		text := p.statement(stmt, depth)
+		if strings.Contains(text, "\n") {
+			return "", false
+		}
+
+		var following ast.Statement
+		if i+1 < len(b.Statements) {
+			following = b.Statements[i+1]
+		}
+		if p.needsSemicolon(stmt, following, i == len(b.Statements)-1, depth) {
+			text += ";"
+		}
+		parts[i] = text
+	}
+	return "{ " + strings.Join(parts, " ") + " }", true
+}
+
+// precedence tells how tightly exp holds together, on
+// the parser's scale.
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(exp.Token.Type)
+	case *ast.LogicalExpression:
+		return parser.Precedence(exp.Token.Type)
+	case *ast.AssignExpression:
+		return parser.ASSIGNMENT
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression:
+		return parser.INDEX
+	}
+	return atom
+}
+
+// operand prints exp, in parentheses if it binds less
+// tightly than min.
+func (p *printer) operand(exp ast.Expression, min, depth int) string {
+	text := p.expression(exp, depth)
+	if precedence(exp) < min {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// callee prints what is called or indexed. An `if` or a
+// function literal there is parenthesized too, so that it
+// does not read as a block followed by a group.
+func (p *printer) callee(exp ast.Expression, depth int) string {
+	switch exp.(type) {
+	case *ast.IfExpression, *ast.FunctionLiteral:
+		return "(" + p.expression(exp, depth) + ")"
+	}
+	return p.operand(exp, parser.CALL, depth)
+}
+
+// binary prints `left op right` at precedence prec. Left
+// associative operators need parentheses around a right
+// operand of the same precedence, and right associative
+// ones (`**` and assignments) around such a left operand.
+func (p *printer) binary(left ast.Expression, op string, right ast.Expression, prec int, rightAssoc bool, depth int) string {
+	leftMin, rightMin := prec, prec+1
+	if rightAssoc {
+		leftMin, rightMin = prec+1, prec
+	}
+	return p.operand(left, leftMin, depth) + " " + op + " " + p.operand(right, rightMin, depth)
+}
+
+func (p *printer) expression(exp ast.Expression, depth int) string {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		return exp.Value
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Boolean:
+		return exp.TokenLiteral()
+	case *ast.StringLiteral:
+		// As written, escapes included
+		return p.src[exp.Token.Pos.Offset:exp.Token.End.Offset]
+	case *ast.ArrayLiteral:
+		return "[" + p.list(exp.Elements, depth) + "]"
+	case *ast.HashLiteral:
+		pairs := make([]string, len(exp.Pairs))
+		for i, pair := range exp.Pairs {
+			pairs[i] = p.expression(pair.Key, depth) + ": " + p.expression(pair.Value, depth)
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case *ast.IndexExpression:
+		return p.callee(exp.Left, depth) + "[" + p.expression(exp.Index, depth) + "]"
+	case *ast.CallExpression:
+		return p.callee(exp.Function, depth) + "(" + p.list(exp.Arguments, depth) + ")"
+	case *ast.PrefixExpression:
+		return exp.Operator + p.operand(exp.Right, parser.PREFIX, depth)
+	case *ast.InfixExpression:
+		prec := parser.Precedence(exp.Token.Type)
+		return p.binary(exp.Left, exp.Operator, exp.Right, prec, prec == parser.POWER, depth)
+	case *ast.LogicalExpression:
+		return p.binary(exp.Left, exp.Operator, exp.Right, parser.Precedence(exp.Token.Type), false, depth)
+	case *ast.AssignExpression:
+		return p.binary(exp.Target, exp.Operator, exp.Value, parser.ASSIGNMENT, true, depth)
+	case *ast.IfExpression:
+		out := "if (" + p.expression(exp.Condition, depth) + ") " + p.block(exp.Consequence, depth)
+		if exp.Alternative != nil {
+			out += " else " + p.block(exp.Alternative, depth)
+		}
+		return out
+	case *ast.FunctionLiteral:
+		params := make([]string, len(exp.Parameters))
+		for i, param := range exp.Parameters {
+			params[i] = param.Value
+		}
+		return "fn(" + strings.Join(params, ", ") + ") " + p.block(exp.Body, depth)
+	}
+	return exp.AsString()
+}
+
+func (p *printer) list(exps []ast.Expression, depth int) string {
+	parts := make([]string, len(exps))
+	for i, exp := range exps {
+		parts[i] = p.expression(exp, depth)
+	}
+	return strings.Join(parts, ", ")
+}
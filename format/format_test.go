@@ -0,0 +1,146 @@
+package format
+
+import (
+	"nexus/lexer"
+	"nexus/parser"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1", "let x = 1;\n"},
+		{"let add = fn(a,b){ a+b }", "let add = fn(a, b) { a + b };\n"},
+		{"puts(1)\nputs(2)", "puts(1);\nputs(2);\n"},
+		{"return", "return;\n"},
+		{"(1 + 2) * 3", "(1 + 2) * 3;\n"},
+		{"1 + (2 * 3)", "1 + 2 * 3;\n"},
+		{"a - (b - c)", "a - (b - c);\n"},
+		{"(a - b) - c", "a - b - c;\n"},
+		{"(a ** b) ** c", "(a ** b) ** c;\n"},
+		{"a ** (b ** c)", "a ** b ** c;\n"},
+		{"(-a) ** 2", "(-a) ** 2;\n"},
+		{"-(a ** 2)", "-a ** 2;\n"},
+		{"-(a + b)", "-(a + b);\n"},
+		{"(a && b) || c", "a && b || c;\n"},
+		{"a && (b || c)", "a && (b || c);\n"},
+		{"x = (y = 1)", "x = y = 1;\n"},
+		{"(-f)(x)", "(-f)(x);\n"},
+		{"(a + b)[0]", "(a + b)[0];\n"},
+		{`{"a" : [1,2]}["a"]`, "{\"a\": [1, 2]}[\"a\"];\n"},
+		{`"tab\t\u{e9}"`, "\"tab\\t\\u{e9}\";\n"},
+		{"0xFF + 1_000 + 1.5e3", "0xFF + 1_000 + 1.5e3;\n"},
+		{"for (x in xs) { puts(x); };", "for x in xs { puts(x) }\n"},
+		{"while(true){ break }", "while (true) { break; }\n"},
+		{"if (a) { 1 } else { 2 }\nputs(3)", "if (a) { 1 } else { 2 }\nputs(3);\n"},
+		{"if (a) { 1 };\n(2)", "if (a) { 1 }\n2;\n"},
+		{"(fn(x) { x })(1)", "(fn(x) { x })(1);\n"},
+		{"(if (a) { [1] } else { [2] })[0]", "(if (a) { [1] } else { [2] })[0];\n"},
+		{"if (a) { 1 };\n-2", "if (a) { 1 };\n-2;\n"},
+		{"fn(){}", "fn() {};\n"},
+		{
+			"let f = fn(x) {\nlet y = x;\n\n\ny }",
+			"let f = fn(x) {\n    let y = x;\n\n    y\n};\n",
+		},
+		{
+			"if (x) {\nif (y) { 1 } }",
+			"if (x) {\n    if (y) { 1 }\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out, errs := Source("", tt.input)
+		if len(errs) > 0 {
+			t.Errorf("unexpected errors for %q: %v", tt.input, errs)
+			continue
+		}
+		if out != tt.expected {
+			t.Errorf("wrong output for %q.\nexpected=%q\ngot=     %q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"#!/usr/bin/env nexus\nputs(1)", "#!/usr/bin/env nexus\nputs(1);\n"},
+		{"// only a comment", "// only a comment\n"},
+		{"let x = 1 // one\nlet y = 2", "let x = 1; // one\nlet y = 2;\n"},
+		{"let a = [1, // one\n2]", "let a = [1, 2]; // one\n"},
+		{"x /* a */ // b", "x; /* a */ // b\n"},
+		{"// a\n\n\n// b\nx", "// a\n\n// b\nx;\n"},
+		{"if (x) { /* c */ }", "if (x) { /* c */\n}\n"},
+		{
+			"let f = fn() { // doc\n  1\n  // end\n}",
+			"let f = fn() { // doc\n    1\n    // end\n};\n",
+		},
+		{
+			"let f = fn() {\n  // first\n  1 /* one */\n}",
+			"let f = fn() {\n    // first\n    1 /* one */\n};\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out, errs := Source("", tt.input)
+		if len(errs) > 0 {
+			t.Errorf("unexpected errors for %q: %v", tt.input, errs)
+			continue
+		}
+		if out != tt.expected {
+			t.Errorf("wrong output for %q.\nexpected=%q\ngot=     %q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+// TestSameProgram checks that formatting keeps the meaning
+// of a program, and that formatted code is left as is.
+func TestSameProgram(t *testing.T) {
+	inputs := []string{
+		"let fib = fn(n) { if (n < 2) { return n } fib(n - 1) + fib(n - 2) }; puts(fib(10))",
+		"let h = {\"a\": 1, 2: [true, false]}; for k in h { print(k, h[k]) }",
+		"let i = 0; while (i < 3) { i += 1; if (i == 2) { continue } }",
+		"x = y = -2 ** -(3 % 2) << 1 | 4 & ~5 ^ 6",
+		"!(a == b) != (c <= d) && (e >= f || g > h)",
+		"(fn(x) { x * 2 })(21)",
+		"f(1)(2)[0][1]",
+		"if (a) { 1 } else { 2 } + 3",
+		"let c = if (a) {\n  b\n} else {\n  c\n};\n[1]",
+		"a[i] -= (b = 1)",
+	}
+
+	for _, input := range inputs {
+		out, errs := Source("", input)
+		if len(errs) > 0 {
+			t.Errorf("unexpected errors for %q: %v", input, errs)
+			continue
+		}
+
+		if want, got := parse(t, input), parse(t, out); want != got {
+			t.Errorf("formatting %q changed the program.\nwant=%q\ngot= %q\nformatted=%q", input, want, got, out)
+		}
+		if again, _ := Source("", out); again != out {
+			t.Errorf("formatting %q is not stable.\nonce= %q\ntwice=%q", input, out, again)
+		}
+	}
+}
+
+func parse(t *testing.T, src string) string {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors in %q: %v", src, errs)
+	}
+	return prog.AsString()
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	out, errs := Source("a.nx", "let = 1")
+	if out != "" || len(errs) != 1 || errs[0].Error() != "a.nx:1:5: Expected token to be IDENT, got = instead" {
+		t.Errorf("wrong result. out=%q errs=%v", out, errs)
+	}
+}
@@ -1,38 +1,106 @@
 package lexer
 
 import (
+	"fmt"
+	"io"
+	"nexus/diagnostic"
 	"nexus/token"
 )
 
 type Lexer struct {
-	input   string // Code source
-	pos     uint   // Buffer position
-	readPos uint   // Right limiter
-	ch      byte   // Actual character
+	input         string // Code source
+	filename      string // Name reported in positions, may be empty
+	pos           uint   // Buffer position
+	readPos       uint   // Right limiter
+	ch            rune   // Actual character
+	runePos       int    // Rune position of the actual character
+	line          int    // Line of the actual character
+	lineStartRune int    // Rune position where that line starts
+	errors        []*diagnostic.Diagnostic
+	problem       *diagnostic.Diagnostic // Why the token being read is ILLEGAL
+	comments      []token.Comment
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// NewFile creates a lexer whose token positions
+// carry the given file name. A leading byte order
+// mark is skipped, and so is a `#!` line so scripts
+// can be run directly.
+func NewFile(filename, input string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 	l.readChar()
+	if l.ch == '\uFEFF' {
+		l.readChar()
+		l.lineStartRune = l.runePos
+	}
+	if l.ch == '#' && l.peekNext() == '!' {
+		l.eatComment(l.eatLineComment)
+	}
 	return l
 }
 
-func (l *Lexer) NextToken() token.Token {
-	var t token.Token
+// Errors returns the problems found so far. Every ILLEGAL
+// token has one, as well as unterminated comments.
+func (l *Lexer) Errors() []*diagnostic.Diagnostic {
+	return l.errors
+}
+
+// Comments returns the comments skipped so far, in source
+// order. A leading `#!` line counts as one.
+func (l *Lexer) Comments() []token.Comment {
+	return l.comments
+}
+
+// WriteTokens writes the tokens l reads up to EOF, one per
+// line with their position, type and literal. This is what
+// the REPL :tokens and `nexus tokens` print.
+func WriteTokens(w io.Writer, l *Lexer) {
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(w, "%-7s %-9s %q\n", tok.Pos, tok.Type, tok.Literal)
+	}
+}
 
+// illegal records why the token being read is ILLEGAL.
+// NextToken reports it, spanning the whole token.
+func (l *Lexer) illegal(code diagnostic.Code, format string, a ...any) {
+	l.problem = diagnostic.Errorf(code, token.Position{}, token.Position{}, format, a...)
+}
+
+// NextToken returns the next token, with its
+// start and end positions.
+func (l *Lexer) NextToken() token.Token {
 	l.eatWhitespace()
 
+	pos := l.position()
+	t := l.readToken()
+	t.Pos = pos
+	t.End = l.position()
+
+	if l.problem != nil {
+		l.problem.Pos, l.problem.End = t.Pos, t.End
+		l.errors = append(l.errors, l.problem)
+		l.problem = nil
+	}
+	return t
+}
+
+func (l *Lexer) readToken() token.Token {
+	var t token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peekNext() == '=' {
-			ch := l.ch
-			l.readChar()
-			t = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
+			t = l.readTwoCharToken(token.EQ)
 		} else {
 			t = newToken(token.ASSIGN, l.ch)
 		}
 	case ';':
 		t = newToken(token.SEMICOLON, l.ch)
+	case ':':
+		t = newToken(token.COLON, l.ch)
 	case '(':
 		t = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -43,40 +111,102 @@ func (l *Lexer) NextToken() token.Token {
 		t = newToken(token.LBRACE, l.ch)
 	case '}':
 		t = newToken(token.RBRACE, l.ch)
+	case '[':
+		t = newToken(token.LBRACKET, l.ch)
+	case ']':
+		t = newToken(token.RBRACKET, l.ch)
 	case '+':
-		t = newToken(token.PLUS, l.ch)
+		if l.peekNext() == '=' {
+			t = l.readTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			t = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		t = newToken(token.SUBS, l.ch)
+		if l.peekNext() == '=' {
+			t = l.readTwoCharToken(token.SUBS_ASSIGN)
+		} else {
+			t = newToken(token.SUBS, l.ch)
+		}
 	case '*':
-		t = newToken(token.MULT, l.ch)
+		if l.peekNext() == '=' {
+			t = l.readTwoCharToken(token.MULT_ASSIGN)
+		} else if l.peekNext() == '*' {
+			t = l.readTwoCharToken(token.POW)
+		} else {
+			t = newToken(token.MULT, l.ch)
+		}
 	case '/':
-		t = newToken(token.DIV, l.ch)
+		if l.peekNext() == '=' {
+			t = l.readTwoCharToken(token.DIV_ASSIGN)
+		} else {
+			t = newToken(token.DIV, l.ch)
+		}
+	case '%':
+		t = newToken(token.MOD, l.ch)
 	case '<':
-		t = newToken(token.LT, l.ch)
+		if l.peekNext() == '=' {
+			t = l.readTwoCharToken(token.LTE)
+		} else if l.peekNext() == '<' {
+			t = l.readTwoCharToken(token.SHL)
+		} else {
+			t = newToken(token.LT, l.ch)
+		}
 	case '>':
-		t = newToken(token.GT, l.ch)
+		if l.peekNext() == '=' {
+			t = l.readTwoCharToken(token.GTE)
+		} else if l.peekNext() == '>' {
+			t = l.readTwoCharToken(token.SHR)
+		} else {
+			t = newToken(token.GT, l.ch)
+		}
+	case '^':
+		t = newToken(token.BIT_XOR, l.ch)
+	case '~':
+		t = newToken(token.BIT_NOT, l.ch)
+	case '&':
+		if l.peekNext() == '&' {
+			t = l.readTwoCharToken(token.AND)
+		} else {
+			t = newToken(token.BIT_AND, l.ch)
+		}
+	case '|':
+		if l.peekNext() == '|' {
+			t = l.readTwoCharToken(token.OR)
+		} else {
+			t = newToken(token.BIT_OR, l.ch)
+		}
 	case '!':
 		if l.peekNext() == '=' {
-			ch := l.ch
-			l.readChar()
-			t = token.Token{Type: token.NEQ, Literal: string(ch) + string(l.ch)}
+			t = l.readTwoCharToken(token.NEQ)
 		} else {
 			t = newToken(token.NOT, l.ch)
 		}
+	case '"':
+		if str, ok := l.readString(); ok {
+			t = token.Token{Type: token.STRING, Literal: str}
+		} else {
+			t = token.Token{Type: token.ILLEGAL, Literal: str}
+			if l.ch == 0 {
+				l.illegal(diagnostic.UnterminatedString, "unterminated string literal")
+			} else {
+				l.illegal(diagnostic.InvalidEscape, "invalid escape sequence in string literal %s", str)
+			}
+		}
 	case 0:
 		t.Literal = ""
 		t.Type = token.EOF
 	default:
-		if isLetter(l.ch) {
+		if isIdentStart(l.ch) {
 			t.Literal = l.readIdentifier()
 			t.Type = token.LookupIdent(t.Literal)
 			return t
-		} else if isDigit(l.ch) {
-			t.Type = token.INT
-			t.Literal = l.readNumber()
+		} else if isDigit(l.ch) || l.ch == '.' && isDigit(l.peekNext()) {
+			t.Literal, t.Type = l.readNumber()
 			return t
 		} else {
-			t = newToken(token.ILLEGAL, l.ch)
+			// Raw bytes, so invalid UTF-8 shows up as written
+			t = token.Token{Type: token.ILLEGAL, Literal: l.input[l.pos:l.readPos]}
+			l.illegal(diagnostic.UnexpectedCharacter, "unexpected character %q", t.Literal)
 		}
 	}
 	l.readChar()
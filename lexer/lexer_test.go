@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"bytes"
 	"nexus/token"
 	"testing"
 )
@@ -13,7 +14,7 @@ func TestNextToken(t *testing.T) {
 		return x + y;
 	};
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 
 	if (5 < 10) {
@@ -24,6 +25,14 @@ func TestNextToken(t *testing.T) {
 
 	10 == 10;
 	10 != 0;
+	"foobar"
+	"foo bar"
+	[1, 2];
+	{"foo": "bar"}
+	while for in break continue
+	x += 1; -= *= /=
+	&& ||
+	<= >= % ** & | ^ ~ << >>
 	`
 
 	tests := []struct {
@@ -104,6 +113,43 @@ func TestNextToken(t *testing.T) {
 		{token.NEQ, "!="},
 		{token.INT, "0"},
 		{token.SEMICOLON, ";"},
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.SUBS_ASSIGN, "-="},
+		{token.MULT_ASSIGN, "*="},
+		{token.DIV_ASSIGN, "/="},
+		{token.AND, "&&"},
+		{token.OR, "||"},
+		{token.LTE, "<="},
+		{token.GTE, ">="},
+		{token.MOD, "%"},
+		{token.POW, "**"},
+		{token.BIT_AND, "&"},
+		{token.BIT_OR, "|"},
+		{token.BIT_XOR, "^"},
+		{token.BIT_NOT, "~"},
+		{token.SHL, "<<"},
+		{token.SHR, ">>"},
 		{token.EOF, ""},
 	}
 	l := New(input)
@@ -117,3 +163,393 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input string
+		et    token.TokenType
+		el    string
+	}{
+		{`"a\nb"`, token.STRING, "a\nb"},
+		{`"a\tb"`, token.STRING, "a\tb"},
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"back\\slash"`, token.STRING, `back\slash`},
+		{`"\u{48}\u{49}"`, token.STRING, "HI"},
+		{`"\u{1F600}"`, token.STRING, "\U0001F600"},
+		{`""`, token.STRING, ""},
+		{`"open`, token.ILLEGAL, `"open`},
+		{`"bad \q"`, token.ILLEGAL, `"bad \q"`},
+		{`"\u{110000}"`, token.ILLEGAL, `"\u{110000}"`},
+		{`"\u{}"`, token.ILLEGAL, `"\u{}"`},
+		{`"\u48"`, token.ILLEGAL, `"\u48"`},
+	}
+
+	for i, tt := range tests {
+		tok := New(tt.input).NextToken()
+		if tok.Type != tt.et {
+			t.Errorf("tests[%d] - type is wrong, expected=%q, got=%q", i, tt.et, tok.Type)
+		}
+		if tok.Literal != tt.el {
+			t.Errorf("tests[%d] - literal is wrong, expected=%q, got=%q", i, tt.el, tok.Literal)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		input string
+		et    token.TokenType
+		el    string
+	}{
+		{"42", token.INT, "42"},
+		{"3.14", token.FLOAT, "3.14"},
+		{".5", token.FLOAT, ".5"},
+		{"1e-9", token.FLOAT, "1e-9"},
+		{"2.5E+3", token.FLOAT, "2.5E+3"},
+		{"6e23", token.FLOAT, "6e23"},
+	}
+
+	for i, tt := range tests {
+		tok := New(tt.input).NextToken()
+		if tok.Type != tt.et {
+			t.Errorf("tests[%d] - type is wrong, expected=%q, got=%q", i, tt.et, tok.Type)
+		}
+		if tok.Literal != tt.el {
+			t.Errorf("tests[%d] - literal is wrong, expected=%q, got=%q", i, tt.el, tok.Literal)
+		}
+	}
+
+	// A dot or exponent that is not followed by digits
+	// does not belong to the number.
+	l := New("1.e")
+	for i, tt := range []struct {
+		et token.TokenType
+		el string
+	}{
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "e"},
+		{token.EOF, ""},
+	} {
+		tok := l.NextToken()
+		if tok.Type != tt.et || tok.Literal != tt.el {
+			t.Errorf("tokens[%d] wrong. expected=%q %q, got=%q %q", i, tt.et, tt.el, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// a line comment
+	let x = 1; // trailing
+	/* block */ x /* nested /* block */ still comment */ +
+	/*
+	 multi-line
+	*/ 2 / 3 //`
+
+	expected := []struct {
+		et token.TokenType
+		el string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.INT, "2"},
+		{token.DIV, "/"},
+		{token.INT, "3"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.et || tok.Literal != tt.el {
+			t.Fatalf("tokens[%d] wrong. expected=%q %q, got=%q %q", i, tt.et, tt.el, tok.Type, tok.Literal)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", l.Errors())
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("let x = 1;\n  /* open /* nested */ ")
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	errors := l.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(errors))
+	}
+	expected := "2:3: unterminated block comment"
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 10;\n  x >= 5 /* c */ \"s\""
+
+	expected := []struct {
+		typ   token.TokenType
+		start string
+		end   string
+		off   int
+	}{
+		{token.LET, "main.nx:1:1", "main.nx:1:4", 0},
+		{token.IDENT, "main.nx:1:5", "main.nx:1:6", 4},
+		{token.ASSIGN, "main.nx:1:7", "main.nx:1:8", 6},
+		{token.INT, "main.nx:1:9", "main.nx:1:11", 8},
+		{token.SEMICOLON, "main.nx:1:11", "main.nx:1:12", 10},
+		{token.IDENT, "main.nx:2:3", "main.nx:2:4", 14},
+		{token.GTE, "main.nx:2:5", "main.nx:2:7", 16},
+		{token.INT, "main.nx:2:8", "main.nx:2:9", 19},
+		{token.STRING, "main.nx:2:18", "main.nx:2:21", 29},
+		{token.EOF, "main.nx:2:21", "main.nx:2:21", 32},
+	}
+
+	l := NewFile("main.nx", input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.typ {
+			t.Fatalf("tokens[%d] - wrong type. expected=%q, got=%q", i, tt.typ, tok.Type)
+		}
+		if tok.Pos.String() != tt.start || tok.End.String() != tt.end {
+			t.Errorf("tokens[%d] - wrong span. expected=%s-%s, got=%s-%s", i, tt.start, tt.end, tok.Pos, tok.End)
+		}
+		if tok.Pos.Offset != tt.off {
+			t.Errorf("tokens[%d] - wrong offset. expected=%d, got=%d", i, tt.off, tok.Pos.Offset)
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := "let año = \"日本語\";\nlet 変数_1 = x٣ + ǅ;\nλ€"
+
+	expected := []struct {
+		et  token.TokenType
+		el  string
+		pos string
+	}{
+		{token.LET, "let", "1:1"},
+		{token.IDENT, "año", "1:5"},
+		{token.ASSIGN, "=", "1:9"},
+		{token.STRING, "日本語", "1:11"},
+		{token.SEMICOLON, ";", "1:16"},
+		{token.LET, "let", "2:1"},
+		{token.IDENT, "変数_1", "2:5"},
+		{token.ASSIGN, "=", "2:10"},
+		{token.IDENT, "x٣", "2:12"},
+		{token.PLUS, "+", "2:15"},
+		{token.IDENT, "ǅ", "2:17"},
+		{token.SEMICOLON, ";", "2:18"},
+		{token.IDENT, "λ", "3:1"},
+		{token.ILLEGAL, "€", "3:2"},
+		{token.EOF, "", "3:3"},
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.et || tok.Literal != tt.el {
+			t.Fatalf("tokens[%d] wrong. expected=%q %q, got=%q %q", i, tt.et, tt.el, tok.Type, tok.Literal)
+		}
+		if tok.Pos.String() != tt.pos {
+			t.Errorf("tokens[%d] wrong position. expected=%s, got=%s", i, tt.pos, tok.Pos)
+		}
+	}
+}
+
+func TestRuneAndByteOffsets(t *testing.T) {
+	l := New("\uFEFF\"ñ\" é")
+
+	str := l.NextToken()
+	if str.Pos.Offset != 3 || str.Pos.RuneOffset != 1 || str.Pos.Column != 1 {
+		t.Errorf("wrong string start. got offset=%d rune offset=%d column=%d",
+			str.Pos.Offset, str.Pos.RuneOffset, str.Pos.Column)
+	}
+	if str.End.Offset != 7 || str.End.RuneOffset != 4 {
+		t.Errorf("wrong string end. got offset=%d rune offset=%d", str.End.Offset, str.End.RuneOffset)
+	}
+
+	ident := l.NextToken()
+	if ident.Literal != "é" || ident.Pos.Offset != 8 || ident.Pos.RuneOffset != 5 || ident.Pos.Column != 5 {
+		t.Errorf("wrong identifier. got %q at offset=%d rune offset=%d column=%d",
+			ident.Literal, ident.Pos.Offset, ident.Pos.RuneOffset, ident.Pos.Column)
+	}
+}
+
+func TestShebang(t *testing.T) {
+	l := New("#!/usr/bin/env nexus\nlet x")
+
+	tok := l.NextToken()
+	if tok.Type != token.LET || tok.Pos.Line != 2 || tok.Pos.Column != 1 {
+		t.Errorf("wrong first token. got %s %q at %s", tok.Type, tok.Literal, tok.Pos)
+	}
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected errors: %v", l.Errors())
+	}
+
+	// Only on the first line
+	l = New("x\n#!y")
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != token.ILLEGAL {
+		t.Errorf("expected ILLEGAL for a later #!, got %s", tok.Type)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	l := New("a \xff b")
+
+	expected := []struct {
+		et token.TokenType
+		el string
+	}{
+		{token.IDENT, "a"},
+		{token.ILLEGAL, "\xff"},
+		{token.IDENT, "b"},
+		{token.EOF, ""},
+	}
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.et || tok.Literal != tt.el {
+			t.Fatalf("tokens[%d] wrong. expected=%q %q, got=%q %q", i, tt.et, tt.el, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestIntegerFormats(t *testing.T) {
+	tests := []struct {
+		input string
+		et    token.TokenType
+		el    string
+	}{
+		{"0xFF", token.INT, "0xFF"},
+		{"0Xdead_BEEF", token.INT, "0Xdead_BEEF"},
+		{"0o17", token.INT, "0o17"},
+		{"0b1010_0101", token.INT, "0b1010_0101"},
+		{"0x_FF", token.INT, "0x_FF"},
+		{"1_000_000", token.INT, "1_000_000"},
+		{"1_000.000_1", token.FLOAT, "1_000.000_1"},
+		{"1e1_0", token.FLOAT, "1e1_0"},
+		{"0", token.INT, "0"},
+		{"0.5", token.FLOAT, "0.5"},
+		{"0e3", token.FLOAT, "0e3"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != tt.et || tok.Literal != tt.el {
+			t.Errorf("tests[%d] wrong. expected=%q %q, got=%q %q", i, tt.et, tt.el, tok.Type, tok.Literal)
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("tests[%d] - expected EOF after number, got=%q %q", i, next.Type, next.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Errorf("tests[%d] - unexpected errors: %v", i, l.Errors())
+		}
+	}
+}
+
+func TestMalformedNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0x", "1:1: malformed number 0x: hexadecimal literal has no digits"},
+		{"0b_", "1:1: malformed number 0b_: binary literal has no digits"},
+		{"1__0", "1:1: malformed number 1__0: `_` must separate successive digits"},
+		{"x = 10_;", "1:5: malformed number 10_: `_` must separate successive digits"},
+		{"1_.5", "1:1: malformed number 1_.5: `_` must separate successive digits"},
+		{"0x1__2", "1:1: malformed number 0x1__2: `_` must separate successive digits"},
+		{"0b102", "1:1: malformed number 0b102: invalid digit '2' in binary literal"},
+		{"0o8", "1:1: malformed number 0o8: invalid digit '8' in octal literal"},
+		{"\n  0xFG", "2:3: malformed number 0xFG: invalid digit 'G' in hexadecimal literal"},
+		{"010", "1:1: malformed number 010: leading zeros are not allowed, use 0o for octal"},
+		{"0_10", "1:1: malformed number 0_10: leading zeros are not allowed, use 0o for octal"},
+		{"08", "1:1: malformed number 08: leading zeros are not allowed, use 0o for octal"},
+		{"00", "1:1: malformed number 00: leading zeros are not allowed, use 0o for octal"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected 1 error for %q, got %d: %v", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestIllegalTokensAreReported(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`x = "open`, "1:5: unterminated string literal"},
+		{`"bad \q"`, `1:1: invalid escape sequence in string literal "bad \q"`},
+		{"a @ b", `1:3: unexpected character "@"`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected 1 error for %q, got %d: %v", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestWriteTokens(t *testing.T) {
+	var out bytes.Buffer
+	WriteTokens(&out, NewFile("a.nx", "x = \"é\""))
+
+	expected := "a.nx:1:1 IDENT     \"x\"\na.nx:1:3 =         \"=\"\na.nx:1:5 STRING    \"é\"\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestCommentsAreKept(t *testing.T) {
+	l := New("#!/bin/nexus\nx /* a /* b */ */ // c\n/* open")
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	expected := []struct {
+		text string
+		pos  string
+	}{
+		{"#!/bin/nexus", "1:1"},
+		{"/* a /* b */ */", "2:3"},
+		{"// c", "2:19"},
+		{"/* open", "3:1"},
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d: %v", len(expected), len(comments), comments)
+	}
+	for i, c := range comments {
+		if c.Text != expected[i].text || c.Pos.String() != expected[i].pos {
+			t.Errorf("comments[%d] wrong. expected=%q at %s, got=%q at %s",
+				i, expected[i].text, expected[i].pos, c.Text, c.Pos)
+		}
+	}
+}
@@ -1,56 +1,377 @@
 package lexer
 
-import "nexus/token"
+import (
+	"fmt"
+	"nexus/diagnostic"
+	"nexus/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
-func newToken(tt token.TokenType, ch byte) token.Token {
+func newToken(tt token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tt, Literal: string(ch)}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// isIdentStart reports whether ch can start an identifier:
+// an underscore or a character with the Unicode ID_Start
+// property (UAX #31).
+func isIdentStart(ch rune) bool {
+	if ch < utf8.RuneSelf {
+		return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+	}
+	return unicode.In(ch, unicode.L, unicode.Nl, unicode.Other_ID_Start) && !isPatternChar(ch)
+}
+
+// isIdentPart reports whether ch can continue an identifier,
+// i.e. it has the Unicode ID_Continue property.
+func isIdentPart(ch rune) bool {
+	if ch < utf8.RuneSelf {
+		return isIdentStart(ch) || isDigit(ch)
+	}
+	return isIdentStart(ch) ||
+		unicode.In(ch, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc, unicode.Other_ID_Continue) && !isPatternChar(ch)
+}
+
+// isPatternChar reports whether ch is reserved by Unicode
+// for syntax and may never appear in identifiers.
+func isPatternChar(ch rune) bool {
+	return unicode.In(ch, unicode.Pattern_Syntax, unicode.Pattern_White_Space)
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
 // Utils with recipient (methods if you want)
 
+// readChar decodes the next rune. Invalid UTF-8 reads
+// as utf8.RuneError, one byte at a time.
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.lineStartRune = l.runePos + 1
+	}
+	if l.pos < l.readPos {
+		l.runePos++
+	}
+	l.pos = l.readPos
 	if l.readPos >= uint(len(l.input)) {
 		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPos]
+		return
 	}
-	l.pos = l.readPos
-	l.readPos++
+	r, w := utf8.DecodeRuneInString(l.input[l.readPos:])
+	l.ch = r
+	l.readPos += uint(w)
+}
+
+// readTwoCharToken builds a token out of the current
+// and the next character, leaving the lexer on the latter.
+func (l *Lexer) readTwoCharToken(tt token.TokenType) token.Token {
+	pos := l.pos
+	l.readChar()
+	return token.Token{Type: tt, Literal: l.input[pos:l.readPos]}
 }
 
 func (l *Lexer) readIdentifier() string {
 	pos := l.pos
-	for isLetter(l.ch) {
+	for isIdentPart(l.ch) {
 		l.readChar()
 	}
 	return l.input[pos:l.pos]
 }
 
+// eatWhitespace skips whitespace and comments.
 func (l *Lexer) eatWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekNext() == '/':
+			l.eatComment(l.eatLineComment)
+		case l.ch == '/' && l.peekNext() == '*':
+			l.eatComment(l.eatBlockComment)
+		default:
+			return
+		}
+	}
+}
+
+// eatComment skips a comment with eat, keeping its text.
+func (l *Lexer) eatComment(eat func()) {
+	pos := l.position()
+	eat()
+	end := l.position()
+	l.comments = append(l.comments, token.Comment{Text: l.input[pos.Offset:end.Offset], Pos: pos, End: end})
+}
+
+// eatLineComment skips a `//` comment up to, but not
+// including, the end of the line.
+func (l *Lexer) eatLineComment() {
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+}
+
+// eatBlockComment skips a `/* ... */` comment. Block
+// comments nest, so `/* a /* b */ c */` is one comment.
+func (l *Lexer) eatBlockComment() {
+	start := l.position()
+	depth := 0
+
+	for l.ch != 0 {
+		switch {
+		case l.ch == '/' && l.peekNext() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekNext() == '/':
+			depth--
+			l.readChar()
+		}
 		l.readChar()
+
+		if depth == 0 {
+			return
+		}
 	}
+
+	end := start // Just the opening `/*`
+	end.Offset += 2
+	end.RuneOffset += 2
+	end.Column += 2
+	l.errors = append(l.errors, diagnostic.Errorf(diagnostic.UnterminatedComment, start, end, "unterminated block comment"))
 }
 
-func (l *Lexer) readNumber() string {
+// position returns the position of the actual character.
+func (l *Lexer) position() token.Position {
+	return token.Position{
+		Filename:   l.filename,
+		Offset:     int(l.pos),
+		RuneOffset: l.runePos,
+		Line:       l.line,
+		Column:     l.runePos - l.lineStartRune + 1,
+	}
+}
+
+// integerBases maps the letter of a base prefix such
+// as `0x` to its base and name.
+var integerBases = map[rune]struct {
+	base int
+	name string
+}{
+	'x': {16, "hexadecimal"},
+	'X': {16, "hexadecimal"},
+	'o': {8, "octal"},
+	'O': {8, "octal"},
+	'b': {2, "binary"},
+	'B': {2, "binary"},
+}
+
+// readNumber reads an integer or a float literal. Integers
+// may have a base prefix (`0xFF`, `0o17`, `0b101`); floats
+// have a fractional part (`3.14`, `.5`), an exponent
+// (`1e-9`) or both. Digits can be separated by `_`.
+// Decimal integers cannot start with `0`, so that `010`
+// is not mistaken for octal. Malformed literals are
+// reported and read as ILLEGAL.
+func (l *Lexer) readNumber() (string, token.TokenType) {
+	if l.ch == '0' {
+		if prefix, ok := integerBases[l.peekNext()]; ok {
+			return l.readPrefixedInteger(prefix.base, prefix.name)
+		}
+	}
+
 	pos := l.pos
-	for isDigit(l.ch) {
+	tt := token.TokenType(token.INT)
+
+	ok := l.readDigits()
+	if l.ch == '.' && isDigit(l.peekNext()) {
+		tt = token.FLOAT
 		l.readChar()
+		ok = l.readDigits() && ok
 	}
-	return l.input[pos:l.pos]
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekAt(1)
+		if isDigit(next) || (next == '+' || next == '-') && isDigit(l.peekAt(2)) {
+			tt = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			ok = l.readDigits() && ok
+		}
+	}
+
+	literal := l.input[pos:l.pos]
+	if !ok {
+		l.illegal(diagnostic.MalformedNumber, "malformed number %s: `_` must separate successive digits", literal)
+		return literal, token.ILLEGAL
+	}
+	if tt == token.INT && literal[0] == '0' && len(literal) > 1 {
+		l.illegal(diagnostic.MalformedNumber, "malformed number %s: leading zeros are not allowed, use 0o for octal", literal)
+		return literal, token.ILLEGAL
+	}
+	return literal, tt
 }
 
-func (l *Lexer) peekNext() byte {
-	if l.readPos >= uint(len(l.input)) {
-		return 0
+// readDigits reads decimal digits and `_` separators, and
+// reports whether every separator is followed by a digit.
+func (l *Lexer) readDigits() bool {
+	ok := true
+	for isDigit(l.ch) || l.ch == '_' {
+		if l.ch == '_' && !isDigit(l.peekNext()) {
+			ok = false
+		}
+		l.readChar()
+	}
+	return ok
+}
+
+// readPrefixedInteger reads an integer literal with a base
+// prefix. Letters and digits right after the literal are
+// read as part of it, so that `0b102` is reported as a
+// whole rather than split into several tokens.
+func (l *Lexer) readPrefixedInteger(base int, name string) (string, token.TokenType) {
+	pos := l.pos
+	l.readChar()
+	l.readChar()
+	for isIdentPart(l.ch) {
+		l.readChar()
+	}
+
+	literal := l.input[pos:l.pos]
+	if problem := checkDigits(literal[2:], base, name); problem != "" {
+		l.illegal(diagnostic.MalformedNumber, "malformed number %s: %s", literal, problem)
+		return literal, token.ILLEGAL
+	}
+	return literal, token.INT
+}
+
+// checkDigits tells what is wrong with the digits of an
+// integer literal in the given base, or returns "". One
+// `_` may follow the base prefix, as in `0x_FF`.
+func checkDigits(digits string, base int, name string) string {
+	if strings.Trim(digits, "_") == "" {
+		return name + " literal has no digits"
+	}
+	if strings.Contains(digits, "__") || strings.HasSuffix(digits, "_") {
+		return "`_` must separate successive digits"
+	}
+	for _, ch := range digits {
+		if ch != '_' && digitValue(ch) >= base {
+			return fmt.Sprintf("invalid digit %q in %s literal", ch, name)
+		}
+	}
+	return ""
+}
+
+// digitValue returns the value of a hexadecimal digit,
+// or 16 for any other character.
+func digitValue(ch rune) int {
+	switch {
+	case isDigit(ch):
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch-'a') + 10
+	case 'A' <= ch && ch <= 'F':
+		return int(ch-'A') + 10
+	}
+	return 16
+}
+
+func (l *Lexer) peekNext() rune {
+	return l.peekAt(1)
+}
+
+// peekAt looks n characters ahead of the current one.
+func (l *Lexer) peekAt(n int) rune {
+	var r rune
+	offset := l.readPos
+	for range n {
+		if offset >= uint(len(l.input)) {
+			return 0
+		}
+		var w int
+		r, w = utf8.DecodeRuneInString(l.input[offset:])
+		offset += uint(w)
+	}
+	return r
+}
+
+// readString reads a double-quoted string literal starting
+// at the opening quote and leaves the lexer on the closing
+// one. It returns the unescaped contents, or the raw source
+// text and false when the literal is unterminated or holds
+// an invalid escape sequence.
+func (l *Lexer) readString() (string, bool) {
+	start := l.pos
+	var out strings.Builder
+	valid := true
+
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			if !valid {
+				return l.input[start : l.pos+1], false
+			}
+			return out.String(), true
+		case 0:
+			return l.input[start:l.pos], false
+		case '\\':
+			l.readChar()
+			switch l.ch {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case '"':
+				out.WriteByte('"')
+			case '\\':
+				out.WriteByte('\\')
+			case 'u':
+				r, ok := l.readUnicodeEscape()
+				if !ok {
+					valid = false
+				}
+				out.WriteRune(r)
+			case 0:
+				return l.input[start:l.pos], false
+			default:
+				valid = false
+			}
+		default:
+			out.WriteString(l.input[l.pos:l.readPos])
+		}
+	}
+}
+
+// readUnicodeEscape reads the `{XXXX}` part of a `\u{XXXX}`
+// escape, leaving the lexer on the closing brace.
+func (l *Lexer) readUnicodeEscape() (rune, bool) {
+	if l.peekNext() != '{' {
+		return utf8.RuneError, false
+	}
+	l.readChar()
+
+	pos := l.pos + 1
+	for isHexDigit(l.peekNext()) {
+		l.readChar()
+	}
+	digits := l.input[pos : l.pos+1]
+	if l.peekNext() != '}' || len(digits) == 0 || len(digits) > 6 {
+		return utf8.RuneError, false
+	}
+	l.readChar()
+
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return utf8.RuneError, false
 	}
-	return l.input[l.readPos]
+	return rune(code), true
 }
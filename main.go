@@ -1,10 +1,10 @@
 package main
 
 import (
-	"nexus/repl"
+	"nexus/cli"
 	"os"
 )
 
 func main() {
-	repl.Start(os.Stdin, os.Stdout)
+	os.Exit(cli.Main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 }
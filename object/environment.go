@@ -0,0 +1,80 @@
+package object
+
+import "sort"
+
+// Environment holds the bindings of a scope. Lookups
+// that miss fall back to the outer (enclosing) scope.
+type Environment struct {
+	store  map[string]Object
+	consts map[string]bool
+	outer  *Environment
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{
+		store:  make(map[string]Object),
+		consts: make(map[string]bool),
+	}
+}
+
+// NewEnclosedEnvironment creates a new scope whose
+// lookups fall back to outer.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// Get resolves name in this scope or any enclosing one.
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set binds name in this scope, shadowing any
+// binding with the same name in enclosing scopes.
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	delete(e.consts, name)
+	return val
+}
+
+// Scope returns the scope name is bound in, looking
+// from this scope outwards, or nil if it is unbound.
+func (e *Environment) Scope(name string) *Environment {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			return env
+		}
+	}
+	return nil
+}
+
+// SetConst binds name in this scope and marks it
+// as a constant.
+func (e *Environment) SetConst(name string, val Object) Object {
+	e.store[name] = val
+	e.consts[name] = true
+	return val
+}
+
+// IsConst reports whether name was declared as a
+// constant in this scope. Enclosing scopes are not
+// consulted, so inner scopes may shadow a constant.
+func (e *Environment) IsConst(name string) bool {
+	return e.consts[name]
+}
+
+// Names returns the names bound in this scope, sorted.
+// Enclosing scopes are not included.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,16 +1,42 @@
 package object
 
 import (
+	"bytes"
+	"cmp"
 	"fmt"
+	"hash/fnv"
+	"math/big"
+	"nexus/ast"
+	"nexus/diagnostic"
+	"nexus/token"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 type ObjectType string
 
 const (
-	INTEGER = "INTEGER"
-	BOOLEAN = "BOOLEAN"
-	NULL    = "NULL"
-	RETURN  = "RETURN"
+	INTEGER  = "INTEGER"
+	BIGINT   = "BIGINT"
+	FLOAT    = "FLOAT"
+	BOOLEAN  = "BOOLEAN"
+	STRING   = "STRING"
+	NULL     = "NULL"
+	RETURN   = "RETURN"
+	ERROR    = "ERROR"
+	FUNCTION = "FUNCTION"
+	ARRAY    = "ARRAY"
+	HASH     = "HASH"
+	BUILTIN  = "BUILTIN"
+	RANGE    = "RANGE"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	EXIT     = "EXIT"
+
+	// ANY is not the type of any value. It is used in
+	// builtin signatures to accept arguments of any type.
+	ANY = "ANY"
 )
 
 type Object interface {
@@ -30,6 +56,39 @@ func (i *Integer) Type() ObjectType {
 	return INTEGER
 }
 
+// BigInt is an integer outside the int64 range. The
+// evaluator only produces one when a result does not
+// fit in an Integer, so the two never hold the same value.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (bi *BigInt) Inspect() string {
+	return bi.Value.String()
+}
+
+func (bi *BigInt) Type() ObjectType {
+	return BIGINT
+}
+
+type Float struct {
+	Value float64
+}
+
+// Inspect always shows a float as one, so `2.0`
+// is not mistaken for the integer `2`.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -42,6 +101,232 @@ func (b *Boolean) Type() ObjectType {
 	return BOOLEAN
 }
 
+type String struct {
+	Value string
+}
+
+func (s *String) Inspect() string {
+	return strconv.Quote(s.Value)
+}
+
+func (s *String) Type() ObjectType {
+	return STRING
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType {
+	return ARRAY
+}
+
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// HashKey identifies a hashable value inside a Hash.
+// Equal values of the same type have equal keys.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by objects usable as hash keys.
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (bi *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(bi.Value.Bytes())
+	value := h.Sum64()
+	if bi.Value.Sign() < 0 {
+		value = ^value
+	}
+	return HashKey{Type: bi.Type(), Value: value}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+// HashPair keeps the original key next to its value,
+// so the hash can be printed and iterated.
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH
+}
+
+// SortedPairs returns the pairs ordered by key, so the
+// same hash is always printed and iterated the same way.
+// Keys are ordered by type (booleans, integers, strings),
+// then by value: `{10: a, 2: b}` iterates 2 before 10.
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	slices.SortFunc(pairs, func(a, b HashPair) int {
+		return compareKeys(a.Key, b.Key)
+	})
+	return pairs
+}
+
+// keyRank is the position of a key's type in the order
+// of SortedPairs. Integers of any size rank together.
+func keyRank(key Object) int {
+	switch key.(type) {
+	case *Boolean:
+		return 0
+	case *Integer, *BigInt:
+		return 1
+	case *String:
+		return 2
+	}
+	return 3
+}
+
+func compareKeys(a, b Object) int {
+	if c := cmp.Compare(keyRank(a), keyRank(b)); c != 0 {
+		return c
+	}
+
+	switch a := a.(type) {
+	case *Boolean:
+		return cmp.Compare(a.HashKey().Value, b.(*Boolean).HashKey().Value)
+	case *Integer, *BigInt:
+		return bigValue(a).Cmp(bigValue(b))
+	case *String:
+		return strings.Compare(a.Value, b.(*String).Value)
+	}
+	return strings.Compare(a.Inspect(), b.Inspect())
+}
+
+func bigValue(obj Object) *big.Int {
+	if i, ok := obj.(*Integer); ok {
+		return big.NewInt(i.Value)
+	}
+	return obj.(*BigInt).Value
+}
+
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, pair := range h.SortedPairs() {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+type BuiltinFunction func(args ...Object) Object
+
+// Builtin is a function implemented in Go. When Variadic
+// is false, calls must pass exactly len(Params) arguments,
+// each of the listed type (or any type for ANY). Otherwise
+// Fn is responsible for checking its own arguments.
+type Builtin struct {
+	Name     string
+	Params   []ObjectType
+	Variadic bool
+	Fn       BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN
+}
+
+func (b *Builtin) Inspect() string {
+	return "builtin function " + b.Name
+}
+
+// Range is the half-open sequence of integers from Start
+// up to End (down to, for a negative Step), produced lazily.
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType {
+	return RANGE
+}
+
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// Break and Continue are signals produced by the
+// statements of the same name. They unwind through
+// blocks until they reach the enclosing loop.
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+	return BREAK
+}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE
+}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+// Exit is what `exit` returns: it unwinds evaluation like
+// an error, up to the host, which decides what to do with
+// the code.
+type Exit struct {
+	Code int
+}
+
+func (e *Exit) Type() ObjectType {
+	return EXIT
+}
+
+func (e *Exit) Inspect() string {
+	return fmt.Sprintf("exit(%d)", e.Code)
+}
+
 type Null struct{}
 
 func (n *Null) Type() ObjectType {
@@ -63,3 +348,56 @@ func (rv *ReturnValue) Type() ObjectType {
 func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
+
+type Error struct {
+	Message string
+	Pos     token.Position // Where the error was raised, if known
+	End     token.Position
+}
+
+func (e *Error) Type() ObjectType {
+	return ERROR
+}
+
+func (e *Error) Inspect() string {
+	return "ERROR: " + e.Error()
+}
+
+// Error makes runtime errors usable as Go errors,
+// prefixing the message with its position.
+func (e *Error) Error() string {
+	return e.Diagnostic().Error()
+}
+
+// Diagnostic describes the error like the problems
+// found by the lexer and the parser.
+func (e *Error) Diagnostic() *diagnostic.Diagnostic {
+	return diagnostic.Errorf(diagnostic.RuntimeError, e.Pos, e.End, "%s", e.Message)
+}
+
+// Function is a user-defined function value. It keeps
+// the environment it was defined in, so calls can see
+// the bindings that were in scope at that point.
+type Function struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType {
+	return FUNCTION
+}
+
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.AsString())
+	}
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(f.Body.AsString())
+	out.WriteString("\n}")
+	return out.String()
+}
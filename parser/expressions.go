@@ -1,7 +1,6 @@
 package parser
 
 import (
-	"fmt"
 	"nexus/ast"
 	"nexus/token"
 )
@@ -12,7 +11,6 @@ import (
 func (p *Parser) ParseExpression(prec int) ast.Expression {
 	prefix := p.prefixFns[p.CurrentToken.Type]
 	if prefix == nil {
-		fmt.Println(p.CurrentToken.Type)
 		p.noPrefixParseFnError(p.CurrentToken.Type)
 		return nil
 	}
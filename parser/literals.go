@@ -2,20 +2,46 @@ package parser
 
 import (
 	"errors"
-	"fmt"
+	"math/big"
 	"nexus/ast"
+	"nexus/diagnostic"
 	"strconv"
 )
 
+// ParseIntegerLiteral parses an integer literal. Literals
+// beyond the int64 range are kept as big integers.
 func (p *Parser) ParseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.CurrentToken}
 
 	value, err := strconv.ParseInt(p.CurrentToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if big, ok := new(big.Int).SetString(p.CurrentToken.Literal, 0); ok {
+			lit.Big = big
+			return lit
+		}
+	}
 	if err != nil {
-		msg := fmt.Sprintf("Cannot parse %q as integer", p.CurrentToken)
-		p.errors = append(p.errors, errors.New(msg))
+		p.errorAt(diagnostic.InvalidLiteral, p.CurrentToken.Pos, p.CurrentToken.End,
+			"Cannot parse %q as integer", p.CurrentToken.Literal)
 	}
 	lit.Value = value
 
 	return lit
 }
+
+func (p *Parser) ParseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.CurrentToken}
+
+	value, err := strconv.ParseFloat(p.CurrentToken.Literal, 64)
+	if err != nil {
+		p.errorAt(diagnostic.InvalidLiteral, p.CurrentToken.Pos, p.CurrentToken.End,
+			"Cannot parse %q as float", p.CurrentToken.Literal)
+	}
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) ParseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.CurrentToken, Value: p.CurrentToken.Literal}
+}
@@ -1,9 +1,8 @@
 package parser
 
 import (
-	"errors"
-	"fmt"
 	"nexus/ast"
+	"nexus/diagnostic"
 	"nexus/lexer"
 	"nexus/token"
 )
@@ -11,12 +10,21 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGNMENT  // = +=
+	OR          // ||
+	AND         // &&
 	EQUALS      // ==
-	LESSGREATER // > <
+	LESSGREATER // > < >= <=
+	BITOR       // |
+	BITXOR      // ^
+	BITAND      // &
+	SHIFT       // << >>
 	SUM         // +
-	PRODUCT     // *
-	PREFIX      // - !
+	PRODUCT     // * / %
+	PREFIX      // - ! ~
+	POWER       // **
 	CALL        // foo()
+	INDEX       // array[index]
 )
 
 type (
@@ -28,19 +36,25 @@ type Parser struct {
 	lex          *lexer.Lexer
 	CurrentToken token.Token
 	PeekToken    token.Token
-	errors       []error
+	errors       []*diagnostic.Diagnostic
 	prefixFns    map[token.TokenType]PrefixParseFn
 	infixFns     map[token.TokenType]InfixParseFn
+	loopDepth    int // Loops enclosing the current token, within the current function
+	failures     int // Syntax errors met so far, including the ones the lexer reported
+	stmtFailures int // Value of failures when the current statement started
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{lex: l, errors: []error{}}
+	p := &Parser{lex: l, errors: []*diagnostic.Diagnostic{}}
 
 	p.prefixFns = make(map[token.TokenType]PrefixParseFn)
 	p.registerPrefix(token.IDENT, p.ParseIdentifier)
 	p.registerPrefix(token.INT, p.ParseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.ParseFloatLiteral)
+	p.registerPrefix(token.STRING, p.ParseStringLiteral)
 	p.registerPrefix(token.NOT, p.ParsePrefixExpression)
 	p.registerPrefix(token.SUBS, p.ParsePrefixExpression)
+	p.registerPrefix(token.BIT_NOT, p.ParsePrefixExpression)
 
 	p.infixFns = make(map[token.TokenType]InfixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -51,7 +65,24 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NEQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LTE, p.parseInfixExpression)
+	p.registerInfix(token.GTE, p.parseInfixExpression)
+	p.registerInfix(token.MOD, p.parseInfixExpression)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token.SHL, p.parseInfixExpression)
+	p.registerInfix(token.SHR, p.parseInfixExpression)
+	p.registerInfix(token.POW, p.parsePowerExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.AND, p.parseLogicalExpression)
+	p.registerInfix(token.OR, p.parseLogicalExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SUBS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MULT_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.DIV_ASSIGN, p.parseAssignExpression)
 
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
@@ -62,6 +93,14 @@ func New(l *lexer.Lexer) *Parser {
 
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+
+	// Blocks are only parsed where the grammar expects
+	// one (after `if`, `else`, `fn`, `while` and `for`),
+	// so a '{' reached in expression position always
+	// opens a hash literal.
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+
 	// To populate both, curr and peek tokens
 	p.nextToken()
 	p.nextToken()
@@ -96,15 +135,35 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
-func (p *Parser) Errors() []error {
-	// Public getter for errors
-	return p.errors
+func (p *Parser) Errors() []*diagnostic.Diagnostic {
+	// Public getter for errors, including the
+	// ones the lexer found along the way
+	errs := append([]*diagnostic.Diagnostic{}, p.lex.Errors()...)
+	return append(errs, p.errors...)
 }
 
+// peekError reports an unexpected token, unless the
+// statement already has an error: then the token is most
+// likely a consequence of it, so it only counts as one.
 func (p *Parser) peekError(t token.TokenType) {
-	// Append error
-	msg := fmt.Sprintf("Expected token to be %s, got %s instead", t, p.PeekToken.Type)
-	p.errors = append(p.errors, errors.New(msg))
+	if p.failures > p.stmtFailures {
+		p.failures++
+		return
+	}
+
+	d := p.errorAt(diagnostic.UnexpectedToken, p.PeekToken.Pos, p.PeekToken.End,
+		"Expected token to be %s, got %s instead", t, p.PeekToken.Type)
+	d.Expected = []string{string(t)}
+	d.Got = string(p.PeekToken.Type)
+}
+
+// errorAt records an error spanning from pos to end,
+// and returns it so details can be added.
+func (p *Parser) errorAt(code diagnostic.Code, pos, end token.Position, format string, a ...any) *diagnostic.Diagnostic {
+	d := diagnostic.Errorf(code, pos, end, format, a...)
+	p.errors = append(p.errors, d)
+	p.failures++
+	return d
 }
 
 func (p *Parser) registerPrefix(t token.TokenType, fn PrefixParseFn) {
@@ -116,8 +175,12 @@ func (p *Parser) registerInfix(t token.TokenType, fn InfixParseFn) {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, errors.New(msg))
+	if t == token.ILLEGAL {
+		p.failures++ // Already reported by the lexer
+		return
+	}
+	p.errorAt(diagnostic.ExpectedExpression, p.CurrentToken.Pos, p.CurrentToken.End,
+		"no prefix parse function for %s found", t)
 }
 
 func (p *Parser) ParsePrefixExpression() ast.Expression {
@@ -132,29 +195,49 @@ func (p *Parser) ParsePrefixExpression() ast.Expression {
 }
 
 var precedences = map[token.TokenType]int{
-	token.EQ:     EQUALS,
-	token.NEQ:    EQUALS,
-	token.LT:     LESSGREATER,
-	token.GT:     LESSGREATER,
-	token.PLUS:   SUM,
-	token.SUBS:   SUM,
-	token.DIV:    PRODUCT,
-	token.MULT:   PRODUCT,
-	token.LPAREN: CALL,
+	token.ASSIGN:      ASSIGNMENT,
+	token.PLUS_ASSIGN: ASSIGNMENT,
+	token.SUBS_ASSIGN: ASSIGNMENT,
+	token.MULT_ASSIGN: ASSIGNMENT,
+	token.DIV_ASSIGN:  ASSIGNMENT,
+	token.OR:          OR,
+	token.AND:         AND,
+	token.EQ:          EQUALS,
+	token.NEQ:         EQUALS,
+	token.LT:          LESSGREATER,
+	token.GT:          LESSGREATER,
+	token.LTE:         LESSGREATER,
+	token.GTE:         LESSGREATER,
+	token.BIT_OR:      BITOR,
+	token.BIT_XOR:     BITXOR,
+	token.BIT_AND:     BITAND,
+	token.SHL:         SHIFT,
+	token.SHR:         SHIFT,
+	token.PLUS:        SUM,
+	token.SUBS:        SUM,
+	token.DIV:         PRODUCT,
+	token.MULT:        PRODUCT,
+	token.MOD:         PRODUCT,
+	token.POW:         POWER,
+	token.LPAREN:      CALL,
+	token.LBRACKET:    INDEX,
 }
 
-func (p *Parser) currPrecedence() int {
-	if p, ok := precedences[p.CurrentToken.Type]; ok {
+// Precedence returns how tightly the operator t binds
+// its operands, or LOWEST if t is not an infix operator.
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
 		return p
 	}
 	return LOWEST
 }
 
+func (p *Parser) currPrecedence() int {
+	return Precedence(p.CurrentToken.Type)
+}
+
 func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.PeekToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return Precedence(p.PeekToken.Type)
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
@@ -169,6 +252,54 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parsePowerExpression parses `**`, which is right-associative:
+// `a ** b ** c` is `a ** (b ** c)`. It binds tighter than a
+// prefix operator on its left, so `-a ** b` is `-(a ** b)`.
+func (p *Parser) parsePowerExpression(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{
+		Token:    p.CurrentToken,
+		Operator: p.CurrentToken.Literal,
+		Left:     left,
+	}
+	p.nextToken()
+	expression.Right = p.ParseExpression(POWER - 1)
+	return expression
+}
+
+func (p *Parser) parseLogicalExpression(left ast.Expression) ast.Expression {
+	expression := &ast.LogicalExpression{
+		Token:    p.CurrentToken,
+		Operator: p.CurrentToken.Literal,
+		Left:     left,
+	}
+	prec := p.currPrecedence()
+	p.nextToken()
+	expression.Right = p.ParseExpression(prec)
+	return expression
+}
+
+// parseAssignExpression parses the right side of an
+// assignment. Assignment is right-associative, so the
+// value is parsed one precedence level lower.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.CurrentToken,
+		Operator: p.CurrentToken.Literal,
+		Target:   target,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression, nil:
+	default:
+		p.errorAt(diagnostic.InvalidAssignTarget, target.Pos(), target.End(),
+			"invalid assignment target: %s", target.AsString())
+	}
+
+	p.nextToken()
+	expression.Value = p.ParseExpression(ASSIGNMENT - 1)
+	return expression
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.CurrentToken, Value: p.CurrentTokenIs(token.TRUE)}
 }
@@ -217,17 +348,18 @@ func (p *Parser) parseIfExpression() ast.Expression {
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.CurrentToken}
-	block.Statements = []ast.Statement{}
 
 	p.nextToken()
 
-	for !p.CurrentTokenIs(token.RBRACE) && !p.CurrentTokenIs(token.EOF) {
-		stmt := p.ParseStatement()
-		if stmt != nil {
-			block.Statements = append(block.Statements, stmt)
-		}
-		p.nextToken()
+	block.Statements = p.parseStatements(token.RBRACE)
+	if p.CurrentTokenIs(token.EOF) {
+		d := p.errorAt(diagnostic.UnexpectedToken, p.CurrentToken.Pos, p.CurrentToken.End,
+			"Expected token to be }, got EOF instead")
+		d.Expected = []string{token.RBRACE}
+		d.Got = token.EOF
+		d.Notes = append(d.Notes, "unclosed { at "+block.Token.Pos.String())
 	}
+	block.Close = p.CurrentToken
 
 	return block
 }
@@ -240,12 +372,19 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 
 	lit.Parameters = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	// break and continue cannot cross a function boundary.
+	depth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = depth
 
 	return lit
 }
@@ -258,13 +397,17 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 		return identifiers
 	}
 
-	p.nextToken()
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
 	ident := &ast.Identifier{Token: p.CurrentToken, Value: p.CurrentToken.Literal}
 	identifiers = append(identifiers, ident)
 
 	for p.PeekTokenIs(token.COMMA) {
 		p.nextToken()
-		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
 		ident := &ast.Identifier{Token: p.CurrentToken, Value: p.CurrentToken.Literal}
 		identifiers = append(identifiers, ident)
 	}
@@ -278,25 +421,77 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.CurrentToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Close = p.CurrentToken
 	return exp
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
-	if p.PeekTokenIs(token.RPAREN) {
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.CurrentToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Close = p.CurrentToken
+	return array
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.CurrentToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.ParseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.Close = p.CurrentToken
+	return exp
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.CurrentToken}
+	hash.Pairs = []ast.HashPair{}
+
+	for !p.PeekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.ParseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.ParseExpression(LOWEST)
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.PeekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	hash.Close = p.CurrentToken
+
+	return hash
+}
+
+// parseExpressionList parses comma separated expressions
+// up to the end token, as in call arguments and arrays.
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+	if p.PeekTokenIs(end) {
 		p.nextToken()
-		return args
+		return list
 	}
 	p.nextToken()
-	args = append(args, p.ParseExpression(LOWEST))
+	list = append(list, p.ParseExpression(LOWEST))
 	for p.PeekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.ParseExpression(LOWEST))
+		list = append(list, p.ParseExpression(LOWEST))
 	}
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
 		return nil
 	}
-	return args
+	return list
 }
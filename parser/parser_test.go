@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"nexus/ast"
+	"nexus/diagnostic"
 	"nexus/lexer"
 	"testing"
 )
@@ -40,6 +41,42 @@ func TestLetStatement(t *testing.T) {
 	}
 }
 
+func TestConstStatement(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedIdentifier string
+		expectedValue      any
+	}{
+		{"const x = 5;", "x", 5},
+		{"const LIMIT = 10;", "LIMIT", 10},
+		{"const flag = true;", "flag", true},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements, got %d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ConstStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.ConstStatement. got=%T", program.Statements[0])
+		}
+		if stmt.TokenLiteral() != "const" {
+			t.Errorf("stmt.TokenLiteral not 'const'. got=%q", stmt.TokenLiteral())
+		}
+		if stmt.Name.Value != tt.expectedIdentifier {
+			t.Errorf("stmt.Name.Value not '%s'. got=%s", tt.expectedIdentifier, stmt.Name.Value)
+		}
+		if !testLiteralExpression(t, stmt.Value, tt.expectedValue) {
+			return
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.TokenLiteral())
@@ -167,6 +204,116 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	p := New(lexer.New("123456789012345678901234567890;"))
+	prog := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := prog.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Big == nil || literal.Big.String() != "123456789012345678901234567890" {
+		t.Errorf("literal.Big wrong. got=%v", literal.Big)
+	}
+}
+
+func TestIntegerLiteralFormats(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0x_7fff_ffff_ffff_ffff", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		prog := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := prog.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value wrong for %q. expected=%d, got=%d", tt.input, tt.expected, literal.Value)
+		}
+		if literal.AsString() != tt.input {
+			t.Errorf("literal.AsString() wrong. expected=%q, got=%q", tt.input, literal.AsString())
+		}
+	}
+
+	p := New(lexer.New("0x1_0000_0000_0000_0000"))
+	prog := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	literal := prog.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IntegerLiteral)
+	if literal.Big == nil || literal.Big.String() != "18446744073709551616" {
+		t.Errorf("literal.Big wrong. got=%v", literal.Big)
+	}
+}
+
+func TestMalformedNumberReportedOnce(t *testing.T) {
+	p := New(lexer.New("let x = 0x;"))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errors), errors)
+	}
+	if errors[0].Error() != "1:9: malformed number 0x: hexadecimal literal has no digits" {
+		t.Errorf("wrong error. got=%q", errors[0].Error())
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{".5;", 0.5},
+		{"1e-9;", 1e-9},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		prog := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := prog.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	p := New(lexer.New(`"hello world";`))
+	prog := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := prog.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -391,6 +538,86 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"fns[0](1)",
+			"(fns[0])(1)",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a == b && c != d",
+			"((a == b) && (c != d))",
+		},
+		{
+			"x = a || b",
+			"(x = (a || b))",
+		},
+		{
+			"!a && b",
+			"((!a) && b)",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a * b % c",
+			"((a * b) % c)",
+		},
+		{
+			"a ** b ** c",
+			"(a ** (b ** c))",
+		},
+		{
+			"-a ** b",
+			"(-(a ** b))",
+		},
+		{
+			"a * b ** c",
+			"(a * (b ** c))",
+		},
+		{
+			"a ** -b",
+			"(a ** (-b))",
+		},
+		{
+			"a | b ^ c & d",
+			"(a | (b ^ (c & d)))",
+		},
+		{
+			"a & b == c",
+			"((a & b) == c)",
+		},
+		{
+			"a << b + c",
+			"(a << (b + c))",
+		},
+		{
+			"a >> b < c",
+			"((a >> b) < c)",
+		},
+		{
+			"~a & b",
+			"((~a) & b)",
+		},
+		{
+			"a | b && c",
+			"((a | b) && c)",
+		},
 	}
 	for _, tt := range tests {
 		p := New(lexer.New(tt.input))
@@ -647,6 +874,186 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestParsingArrayLiterals(t *testing.T) {
+	p := New(lexer.New("[1, 2 * 2, 3 + 3]"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	p := New(lexer.New("myArray[1 + 1]"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{}`, `{}`},
+		{`{"one": 1, "two": 2}`, `{"one": 1, "two": 2}`},
+		{`{1: true, true: "x",}`, `{1: true, true: "x"}`},
+		{`{"one": 0 + 1, "two": 10 - 8}`, `{"one": (0 + 1), "two": (10 - 8)}`},
+		{`if (x) { {"a": 1} }`, `ifx {"a": 1}`},
+		{`let h = {"f": fn(x) { x }}; h["f"](1)`, `let h = {"f": fn(x) x};(h["f"])(1)`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.AsString(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestLoopStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (x < 10) { x }", "while(x < 10) x"},
+		{"for x in xs { puts(x); }", "for x in xs puts(x)"},
+		{"for (x in [1, 2]) { x }", "for x in [1, 2] x"},
+		{"while (true) { break; continue }", "whiletrue break;continue;"},
+		{"for x in xs { if (x) { break } }", "for x in xs ifx break;"},
+		{"while (c) { x };", "whilec x"},
+		{"for x in [1] { 1 };", "for x in [1] 1"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		if actual := program.AsString(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestLoopFollowedBySemicolon(t *testing.T) {
+	for _, input := range []string{"for x in [1] { 1 }; puts(2)", "while (c) { 1 }; puts(2)"} {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 2 {
+			t.Errorf("%q: expected 2 statements, got=%d", input, len(program.Statements))
+		}
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break outside loop"},
+		{"continue;", "1:1: continue outside loop"},
+		{"while (true) { fn() { break; } }", "1:23: break outside loop"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("expected 1 error for %q, got %d", tt.input, len(errors))
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestAssignExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x = x + 1", "(x = (x + 1))"},
+		{"x += 1 * 2", "(x += (1 * 2))"},
+		{"x -= 1", "(x -= 1)"},
+		{"x *= 1", "(x *= 1)"},
+		{"x /= 1", "(x /= 1)"},
+		{"x = y = 1", "(x = (y = 1))"},
+		{"a[i] = v", "((a[i]) = v)"},
+		{`m["k"] = v == w`, `((m["k"]) = (v == w))`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.AsString(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+	p := New(lexer.New("1 + 2 = 3"))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(errors))
+	}
+	if errors[0].Error() != "1:1: invalid assignment target: (1 + 2)" {
+		t.Errorf("wrong error. got=%q", errors[0].Error())
+	}
+}
+
+func TestLexerErrorsAreReported(t *testing.T) {
+	p := New(lexer.New("let x = 1; /* unterminated"))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(errors))
+	}
+	if errors[0].Error() != "1:12: unterminated block comment" {
+		t.Errorf("wrong error. got=%q", errors[0].Error())
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 +5);"
 
@@ -681,3 +1088,208 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
+
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input string
+		start string
+		end   string
+	}{
+		{"let x = 1 + 2;", "1:1", "1:14"},
+		{"foo(1, 2)", "1:1", "1:10"},
+		{"  a[10]", "1:3", "1:8"},
+		{"if (x) {\n  y\n} else {\n  z\n}", "1:1", "5:2"},
+		{"fn(a) { a }", "1:1", "1:12"},
+		{"-x * 3", "1:1", "1:7"},
+		{"{\"k\": [1]}", "1:1", "1:11"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0]
+		if stmt.Pos().String() != tt.start || stmt.End().String() != tt.end {
+			t.Errorf("%q - wrong span. expected=%s-%s, got=%s-%s", tt.input, tt.start, tt.end, stmt.Pos(), stmt.End())
+		}
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	p := New(lexer.NewFile("main.nx", "let x = 1;\nlet = 2;"))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+	expected := "main.nx:2:5: Expected token to be IDENT, got = instead"
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestMissingSemicolonAtEOF(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5", "let x = 5;"},
+		{"const y = 1", "const y = 1;"},
+		{"return 5", "return 5;"},
+		{"return", "return;"},
+		{"fn() { return }", "fn() return;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.AsString(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		errors   []string
+		expected string
+	}{
+		{
+			"let = 1;\nlet y = ;\nlet z = 3;\nz",
+			[]string{
+				"1:5: Expected token to be IDENT, got = instead",
+				"2:9: no prefix parse function for ; found",
+			},
+			"let z = 3;z",
+		},
+		{
+			"let x = 1 +\nlet y = 2;",
+			[]string{"2:1: no prefix parse function for LET found"},
+			"let y = 2;",
+		},
+		{
+			"let f = fn() { let = 1; x }; y",
+			[]string{"1:20: Expected token to be IDENT, got = instead"},
+			"let f = fn() x;y",
+		},
+		{
+			"let a = [1, 2;\nlet b = { if (x) { 1 } };\nb",
+			[]string{
+				"1:14: Expected token to be ], got ; instead",
+				"2:24: Expected token to be :, got } instead",
+			},
+			"b",
+		},
+		{
+			"if (x) { 1",
+			[]string{"1:11: Expected token to be }, got EOF instead"},
+			"",
+		},
+		{
+			"fn(1) {}; 2",
+			[]string{"1:4: Expected token to be IDENT, got INT instead"},
+			"2",
+		},
+		{
+			"}\nlet a = 1; ) let b = 2",
+			[]string{
+				"1:1: no prefix parse function for } found",
+				"2:12: no prefix parse function for ) found",
+			},
+			"let a = 1;let b = 2;",
+		},
+		{
+			"puts(1 +)",
+			[]string{"1:9: no prefix parse function for ) found"},
+			"",
+		},
+		{
+			"let f = fn() { g(1 +) }; 2",
+			[]string{"1:21: no prefix parse function for ) found"},
+			"let f = fn() ;2",
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.errors) {
+			t.Errorf("wrong number of errors for %q. expected=%d, got=%d: %v", tt.input, len(tt.errors), len(errors), errors)
+			continue
+		}
+		for i, err := range errors {
+			if err.Error() != tt.errors[i] {
+				t.Errorf("wrong error %d for %q. expected=%q, got=%q", i, tt.input, tt.errors[i], err.Error())
+			}
+		}
+		if actual := program.AsString(); actual != tt.expected {
+			t.Errorf("wrong program for %q. expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestDiagnosticDetails(t *testing.T) {
+	p := New(lexer.New("let x = (1 + 2;\n1 = 2"))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(errors), errors)
+	}
+
+	d := errors[0]
+	if d.Code != diagnostic.UnexpectedToken || d.Severity != diagnostic.Error {
+		t.Errorf("wrong code or severity. got=%s %s", d.Code, d.Severity)
+	}
+	if len(d.Expected) != 1 || d.Expected[0] != ")" || d.Got != ";" {
+		t.Errorf("wrong expected/got. got=%v/%q", d.Expected, d.Got)
+	}
+	if d.Pos.String() != "1:15" || d.End.String() != "1:16" {
+		t.Errorf("wrong span. got=%s-%s", d.Pos, d.End)
+	}
+
+	d = errors[1]
+	if d.Code != diagnostic.InvalidAssignTarget || d.Pos.String() != "2:1" || d.End.String() != "2:2" {
+		t.Errorf("wrong diagnostic. got=%s %s-%s", d.Code, d.Pos, d.End)
+	}
+}
+
+func TestTree(t *testing.T) {
+	input := `for x in [1, 2] {
+  m["k"] += fn(a, b) { return a }(x, 2);
+}`
+	expected := `Program 1:1
+  ForStatement x 1:1
+    iterable: ArrayLiteral 1:10
+      IntegerLiteral 1 1:11
+      IntegerLiteral 2 1:14
+    body: BlockStatement 1:17
+      ExpressionStatement 2:3
+        AssignExpression += 2:3
+          target: IndexExpression 2:3
+            left: Identifier m 2:3
+            index: StringLiteral "k" 2:5
+          value: CallExpression 2:13
+            function: FunctionLiteral (a, b) 2:13
+              body: BlockStatement 2:22
+                ReturnStatement 2:24
+                  Identifier a 2:31
+            argument: Identifier x 2:35
+            argument: IntegerLiteral 2 2:38
+`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if actual := ast.Tree(program); actual != expected {
+		t.Errorf("wrong tree. expected=\n%s\ngot=\n%s", expected, actual)
+	}
+}
@@ -5,16 +5,87 @@ import (
 	"nexus/token"
 )
 
+// ParseProgram parses the whole input. It never stops at
+// the first syntax error: statements with errors are left
+// out of the program and parsing resumes after them, so
+// Errors holds every problem found.
 func (p *Parser) ParseProgram() *ast.Program {
 	prog := &ast.Program{} // ast root
-	prog.Statements = []ast.Statement{}
+	prog.Statements = p.parseStatements(token.EOF)
 
-	for p.CurrentToken.Type != token.EOF {
-		if stmt := p.ParseStatement(); stmt != nil {
-			prog.Statements = append(prog.Statements, stmt)
+	return prog
+}
+
+// parseStatements parses statements until the end token
+// or EOF is reached, leaving the parser on it.
+func (p *Parser) parseStatements(end token.TokenType) []ast.Statement {
+	statements := []ast.Statement{}
+
+	outer := p.stmtFailures
+	defer func() { p.stmtFailures = outer }()
+
+	for !p.CurrentTokenIs(end) && !p.CurrentTokenIs(token.EOF) {
+		start := p.CurrentToken
+		failures := p.failures
+		p.stmtFailures = failures
+
+		stmt := p.ParseStatement()
+		if p.failures > failures {
+			// Recovered here, so enclosing statements
+			// are not thrown away as well
+			p.synchronize(start, end)
+			p.failures = failures
+			continue
+		}
+		if stmt != nil {
+			statements = append(statements, stmt)
 		}
 		p.nextToken()
 	}
 
-	return prog
+	return statements
+}
+
+// statementKeywords are the tokens that can only
+// start a statement, or most likely do.
+var statementKeywords = map[token.TokenType]bool{
+	token.LET:      true,
+	token.CON:      true,
+	token.RET:      true,
+	token.IF:       true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+}
+
+// synchronize skips the rest of a statement that has
+// syntax errors. It stops right after a `;`, or on a
+// statement keyword, EOF or, within a block, on a `}`,
+// where the next statement is most likely to start.
+// Braced code in between is skipped as a whole.
+func (p *Parser) synchronize(start token.Token, end token.TokenType) {
+	if p.CurrentToken.Pos == start.Pos {
+		p.nextToken()
+	}
+
+	depth := 0
+	for !p.CurrentTokenIs(token.EOF) {
+		switch {
+		case p.CurrentTokenIs(token.LBRACE):
+			depth++
+		case p.CurrentTokenIs(token.RBRACE):
+			if depth == 0 && end == token.RBRACE {
+				return
+			}
+			depth = max(depth-1, 0)
+		case depth > 0:
+		case p.CurrentTokenIs(token.SEMICOLON):
+			p.nextToken()
+			return
+		case statementKeywords[p.CurrentToken.Type]:
+			return
+		}
+		p.nextToken()
+	}
 }
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"nexus/ast"
+	"nexus/diagnostic"
 	"nexus/token"
 )
 
@@ -12,16 +13,25 @@ func (p *Parser) ParseStatement() ast.Statement {
 	switch p.CurrentToken.Type {
 	case token.LET:
 		return p.ParseLetStatement()
+	case token.CON:
+		return p.ParseConstStatement()
 	case token.RET:
 		return p.ParseReturnStatement()
+	case token.WHILE:
+		return p.ParseWhileStatement()
+	case token.FOR:
+		return p.ParseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.ParseLoopControlStatement()
 	default:
 		return p.ParseExpressionStatement()
 	}
 }
 
 // ParseLetStatement is for parsing `let foo = 30;`-like
-// statements, expecting five tokens.
-func (p *Parser) ParseLetStatement() *ast.LetStatement {
+// statements, expecting five tokens. The semicolon may
+// be left out.
+func (p *Parser) ParseLetStatement() ast.Statement {
 	stmt := &ast.LetStatement{Token: p.CurrentToken} // this looks like recursive leaves
 	// This function should be called only on
 	// statements which start with 'let'
@@ -46,9 +56,7 @@ func (p *Parser) ParseLetStatement() *ast.LetStatement {
 	p.nextToken()
 	stmt.Value = p.ParseExpression(LOWEST)
 
-	// Keep reading until there is a semicolon
-	// maybe not really good
-	for !p.CurrentTokenIs(token.SEMICOLON) {
+	if p.PeekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -56,15 +64,43 @@ func (p *Parser) ParseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// ParseConstStatement is for parsing `const foo = 30;`-like
+// statements. It has the same shape as a let statement.
+func (p *Parser) ParseConstStatement() ast.Statement {
+	stmt := &ast.ConstStatement{Token: p.CurrentToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.CurrentToken, Value: p.CurrentToken.Literal}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Value = p.ParseExpression(LOWEST)
+
+	if p.PeekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 // ParseReturnStatement is for parsing `return foo;`-like
-// statements, expecting only three tokens.
+// statements, expecting only three tokens. A bare
+// `return;` has no value.
 func (p *Parser) ParseReturnStatement() ast.Statement {
 	stmt := &ast.ReturnStatement{Token: p.CurrentToken}
-	p.nextToken()
 
-	stmt.ReturnValue = p.ParseExpression(LOWEST)
+	if !p.PeekTokenIs(token.SEMICOLON) && !p.PeekTokenIs(token.RBRACE) && !p.PeekTokenIs(token.EOF) {
+		p.nextToken()
+		stmt.ReturnValue = p.ParseExpression(LOWEST)
+	}
 
-	for !p.CurrentTokenIs(token.SEMICOLON) {
+	if p.PeekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
@@ -83,3 +119,97 @@ func (p *Parser) ParseExpressionStatement() *ast.ExpressionStatement {
 
 	return stmt
 }
+
+// ParseWhileStatement is for parsing `while (cond) { ... }`
+// statements.
+func (p *Parser) ParseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.CurrentToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.ParseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.PeekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// ParseForStatement is for parsing `for x in xs { ... }`
+// statements. The header may also be parenthesized, as
+// in `for (x in xs) { ... }`.
+func (p *Parser) ParseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.CurrentToken}
+
+	parens := p.PeekTokenIs(token.LPAREN)
+	if parens {
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.CurrentToken, Value: p.CurrentToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.ParseExpression(LOWEST)
+
+	if parens && !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.PeekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.parseBlockStatement()
+}
+
+// ParseLoopControlStatement is for parsing `break;` and
+// `continue;`, which are only allowed inside a loop.
+func (p *Parser) ParseLoopControlStatement() ast.Statement {
+	tok := p.CurrentToken
+
+	if p.loopDepth == 0 {
+		p.errorAt(diagnostic.LoopControlOutside, tok.Pos, tok.End, "%s outside loop", tok.Literal)
+	}
+
+	if p.PeekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
@@ -0,0 +1,106 @@
+package repl
+
+import (
+	"fmt"
+	"nexus/ast"
+	"nexus/lexer"
+	"nexus/object"
+	"nexus/parser"
+	"os"
+	"strings"
+	"time"
+)
+
+type command struct {
+	usage string
+	help  string
+	run   func(s *session, arg string)
+}
+
+// commands are looked up by name, without the colon.
+// It is filled in init as :help refers to it.
+var commands map[string]command
+
+// commandOrder is the order :help lists commands in.
+var commandOrder = []string{"tokens", "ast", "env", "load", "reset", "time", "help"}
+
+func init() {
+	commands = map[string]command{
+		"tokens": {":tokens <src>", "list the tokens of src", (*session).tokens},
+		"ast":    {":ast <src>", "show how src parses, flat and as a tree", (*session).ast},
+		"env":    {":env", "list the bindings of the session", (*session).listEnv},
+		"load":   {":load <file>", "run a file in the session", (*session).load},
+		"reset":  {":reset", "forget every binding", (*session).reset},
+		"time":   {":time <src>", "run src and tell how long it took", (*session).time},
+		"help":   {":help", "list the commands", (*session).help},
+	}
+}
+
+// command runs a line starting with a colon.
+func (s *session) command(line string) {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command :%s, see :help\n", name)
+		return
+	}
+	cmd.run(s, strings.TrimSpace(arg))
+}
+
+func (s *session) tokens(src string) {
+	lex := lexer.New(src)
+	lexer.WriteTokens(s.out, lex)
+	printDiagnostics(s.out, lex.Errors(), src)
+}
+
+func (s *session) ast(src string) {
+	par := parser.New(lexer.New(src))
+	prog := par.ParseProgram()
+
+	if err := par.Errors(); len(err) > 0 {
+		printDiagnostics(s.out, err, src)
+		return
+	}
+	ast.Dump(s.out, prog)
+}
+
+func (s *session) listEnv(string) {
+	for _, name := range s.env.Names() {
+		value, _ := s.env.Get(name)
+		if s.env.IsConst(name) {
+			fmt.Fprintf(s.out, "const %s = %s\n", name, value.Inspect())
+		} else {
+			fmt.Fprintf(s.out, "let %s = %s\n", name, value.Inspect())
+		}
+	}
+}
+
+func (s *session) load(path string) {
+	if path == "" {
+		fmt.Fprintln(s.out, "usage: "+commands["load"].usage)
+		return
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	s.run(path, string(src))
+}
+
+func (s *session) reset(string) {
+	s.env = object.NewEnvironment()
+}
+
+func (s *session) time(src string) {
+	start := time.Now()
+	s.run("", src)
+	fmt.Fprintf(s.out, "took %s\n", time.Since(start))
+}
+
+func (s *session) help(string) {
+	for _, name := range commandOrder {
+		cmd := commands[name]
+		fmt.Fprintf(s.out, "%-14s %s\n", cmd.usage, cmd.help)
+	}
+}
@@ -0,0 +1,337 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errInterrupted is returned when Ctrl-C abandons a line.
+var errInterrupted = errors.New("interrupted")
+
+// Keys, as read by readKey. Keys sent as escape sequences
+// get values past the Unicode range.
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlJ     = 10
+	keyCtrlK     = 11
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+
+	keyUp = unicode.MaxRune + 1 + iota
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDelete
+	keyUnknown
+)
+
+// completer returns the possible completions of the word
+// ending at pos in line, and where that word starts.
+type completer func(line []rune, pos int) (int, []string)
+
+// editor reads lines key by key from a terminal in raw
+// mode, with Emacs-like editing keys, history and tab
+// completion.
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  *history
+	complete completer
+}
+
+func newEditor(in io.Reader, out io.Writer, h *history, complete completer) *editor {
+	return &editor{in: bufio.NewReader(in), out: out, history: h, complete: complete}
+}
+
+// readLine reads a line after showing prompt. It returns
+// io.EOF on Ctrl-D at the start of an empty line, and
+// errInterrupted on Ctrl-C.
+func (e *editor) readLine(prompt string) (string, error) {
+	var line []rune
+	pos := 0
+	recalled := len(e.history.lines) // Index of the history line shown
+	var draft []rune                 // The line being typed, while browsing history
+	var pending rune                 // Key left over by a search
+
+	e.refresh(prompt, line, pos)
+	for {
+		key := pending
+		pending = 0
+		if key == 0 {
+			var err error
+			if key, err = e.readKey(); err != nil {
+				return "", err
+			}
+		}
+
+		switch key {
+		case keyEnter, keyCtrlJ:
+			io.WriteString(e.out, "\r\n")
+			return string(line), nil
+		case keyCtrlC:
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(line) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = slices.Delete(line, pos, pos+1)
+			}
+		case keyDelete:
+			if pos < len(line) {
+				line = slices.Delete(line, pos, pos+1)
+			}
+		case keyBackspace, keyCtrlH:
+			if pos > 0 {
+				line = slices.Delete(line, pos-1, pos)
+				pos--
+			}
+		case keyCtrlA, keyHome:
+			pos = 0
+		case keyCtrlE, keyEnd:
+			pos = len(line)
+		case keyCtrlB, keyLeft:
+			pos = max(pos-1, 0)
+		case keyCtrlF, keyRight:
+			pos = min(pos+1, len(line))
+		case keyCtrlK:
+			line = line[:pos]
+		case keyCtrlU:
+			line = slices.Clone(line[pos:])
+			pos = 0
+		case keyCtrlW:
+			start := wordStart(line, pos)
+			line = slices.Delete(line, start, pos)
+			pos = start
+		case keyUp, keyCtrlP:
+			if recalled > 0 {
+				if recalled == len(e.history.lines) {
+					draft = line
+				}
+				recalled--
+				line = []rune(e.history.lines[recalled])
+				pos = len(line)
+			}
+		case keyDown, keyCtrlN:
+			if recalled < len(e.history.lines) {
+				recalled++
+				if recalled == len(e.history.lines) {
+					line = draft
+				} else {
+					line = []rune(e.history.lines[recalled])
+				}
+				pos = len(line)
+			}
+		case keyTab:
+			line, pos = e.completeWord(line, pos)
+		case keyCtrlR:
+			var err error
+			if line, pending, err = e.search(line); err != nil {
+				return "", err
+			}
+			pos = len(line)
+		default:
+			if unicode.IsPrint(key) {
+				line = slices.Insert(line, pos, key)
+				pos++
+			}
+		}
+		e.refresh(prompt, line, pos)
+	}
+}
+
+// readKey reads a key, decoding the escape sequences
+// of the arrow, home, end and delete keys.
+func (e *editor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != keyEscape {
+		return r, err
+	}
+
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if r != '[' && r != 'O' {
+		return keyUnknown, nil
+	}
+
+	var params strings.Builder
+	for {
+		r, _, err = e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if '0' <= r && r <= '9' || r == ';' {
+			params.WriteRune(r)
+			continue
+		}
+
+		switch r {
+		case 'A':
+			return keyUp, nil
+		case 'B':
+			return keyDown, nil
+		case 'C':
+			return keyRight, nil
+		case 'D':
+			return keyLeft, nil
+		case 'H':
+			return keyHome, nil
+		case 'F':
+			return keyEnd, nil
+		case '~':
+			switch params.String() {
+			case "1", "7":
+				return keyHome, nil
+			case "4", "8":
+				return keyEnd, nil
+			case "3":
+				return keyDelete, nil
+			}
+		}
+		return keyUnknown, nil
+	}
+}
+
+// refresh redraws the line and puts the cursor at pos.
+func (e *editor) refresh(prompt string, line []rune, pos int) {
+	var out strings.Builder
+	out.WriteString("\r" + prompt + string(line) + "\x1b[K")
+	if back := len(line) - pos; back > 0 {
+		fmt.Fprintf(&out, "\x1b[%dD", back)
+	}
+	io.WriteString(e.out, out.String())
+}
+
+// wordStart returns where the word before pos starts,
+// skipping the blanks right before pos first.
+func wordStart(line []rune, pos int) int {
+	start := pos
+	for start > 0 && unicode.IsSpace(line[start-1]) {
+		start--
+	}
+	for start > 0 && !unicode.IsSpace(line[start-1]) {
+		start--
+	}
+	return start
+}
+
+// completeWord completes the word before pos as far as
+// the candidates agree, and lists them when they do not.
+func (e *editor) completeWord(line []rune, pos int) ([]rune, int) {
+	if e.complete == nil {
+		return line, pos
+	}
+	start, candidates := e.complete(line, pos)
+	if len(candidates) == 0 {
+		return line, pos
+	}
+
+	word := line[start:pos]
+	prefix := []rune(commonPrefix(candidates))
+	if len(prefix) > len(word) {
+		line = slices.Insert(line, pos, prefix[len(word):]...)
+		return line, pos + len(prefix) - len(word)
+	}
+	if len(candidates) > 1 {
+		io.WriteString(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+	}
+	return line, pos
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
+
+// search runs a reverse incremental search through the
+// history, as started by Ctrl-R: typing narrows it down,
+// Ctrl-R again looks further back, Ctrl-G or Ctrl-C gives
+// up and brings line back. Any other key takes the line
+// found and is returned, to be handled as usual.
+func (e *editor) search(line []rune) ([]rune, rune, error) {
+	var query []rune
+	found := len(e.history.lines) // Index of the line found
+	match := line
+
+	for {
+		status := "reverse-i-search"
+		if len(query) > 0 && found == len(e.history.lines) {
+			status = "failed " + status
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", status, string(query), string(match))
+
+		key, err := e.readKey()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		switch {
+		case key == keyCtrlR:
+			if i := e.findInHistory(string(query), found-1); i >= 0 {
+				found, match = i, []rune(e.history.lines[i])
+			}
+		case key == keyBackspace || key == keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				found, match = len(e.history.lines), line
+				if i := e.findInHistory(string(query), found-1); len(query) > 0 && i >= 0 {
+					found, match = i, []rune(e.history.lines[i])
+				}
+			}
+		case key == keyCtrlG || key == keyCtrlC:
+			return line, 0, nil
+		case unicode.IsPrint(key):
+			query = append(query, key)
+			if i := e.findInHistory(string(query), min(found, len(e.history.lines)-1)); i >= 0 {
+				found, match = i, []rune(e.history.lines[i])
+			} else {
+				found = len(e.history.lines)
+			}
+		default:
+			return match, key, nil
+		}
+	}
+}
+
+// findInHistory returns the index of the latest line
+// holding query, looking back from index from, or -1.
+func (e *editor) findInHistory(query string, from int) int {
+	for i := from; i >= 0; i-- {
+		if strings.Contains(e.history.lines[i], query) {
+			return i
+		}
+	}
+	return -1
+}
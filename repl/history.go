@@ -0,0 +1,82 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// historySize is how many lines are kept.
+const historySize = 1000
+
+// history holds the lines entered so far, oldest first.
+// When it has a path, lines are also appended to that
+// file so that later sessions can recall them.
+type history struct {
+	lines []string
+	path  string
+}
+
+// historyPath returns the history file: $NEXUS_HISTORY,
+// or ~/.nexus_history. Setting NEXUS_HISTORY to an empty
+// string disables it.
+func historyPath() string {
+	if path, ok := os.LookupEnv("NEXUS_HISTORY"); ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".nexus_history")
+}
+
+// loadHistory reads the history file at path. A missing
+// file is an empty history. A file grown well past
+// historySize is cut back to it.
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if path == "" {
+		return h
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return h
+	}
+	for line := range strings.Lines(string(data)) {
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			h.lines = append(h.lines, line)
+		}
+	}
+
+	if n := len(h.lines); n > historySize {
+		h.lines = h.lines[n-historySize:]
+		if n > 2*historySize {
+			os.WriteFile(path, []byte(strings.Join(h.lines, "\n")+"\n"), 0o600)
+		}
+	}
+	return h
+}
+
+// add records line, unless it is blank or the same as
+// the previous one.
+func (h *history) add(line string) {
+	if strings.TrimSpace(line) == "" || len(h.lines) > 0 && h.lines[len(h.lines)-1] == line {
+		return
+	}
+	h.lines = append(h.lines, line)
+	if len(h.lines) > historySize {
+		h.lines = h.lines[1:]
+	}
+
+	if h.path == "" {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(line + "\n")
+}
@@ -2,46 +2,235 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
+	"errors"
 	"io"
+	"nexus/diagnostic"
 	"nexus/evaluator"
 	"nexus/lexer"
+	"nexus/object"
 	"nexus/parser"
+	"nexus/token"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
 )
 
-const PROMPT = ">>> "
+const (
+	PROMPT              = ">>> "
+	CONTINUATION_PROMPT = "... "
+)
+
+func printDiagnostics(w io.Writer, diagnostics []*diagnostic.Diagnostic, source string) {
+	for _, d := range diagnostics {
+		diagnostic.Render(w, d, source, false)
+	}
+}
+
+// session is the state kept between inputs.
+type session struct {
+	env    *object.Environment
+	out    io.Writer
+	exited bool // The program called exit
+}
+
+// lineReader reads the session input line by line.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// scannerReader reads lines as they come, for input
+// that is not a terminal.
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scannerReader) ReadLine(prompt string) (string, error) {
+	io.WriteString(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
 
-func printParseErrors(w io.Writer, err []error) {
-	for _, msg := range err {
-		io.WriteString(w, "\t"+msg.Error()+"\n")
+// terminalReader reads lines with an editor, switching
+// the terminal to raw mode for each of them.
+type terminalReader struct {
+	fd     int
+	editor *editor
+}
+
+func (r *terminalReader) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	line, err := r.editor.readLine(prompt)
+	if err == nil {
+		r.editor.history.add(line)
+	}
+	return line, err
+}
+
+// newLineReader edits lines when both in and out are
+// terminals, and reads them plainly otherwise.
+func newLineReader(in io.Reader, out io.Writer, s *session) lineReader {
+	inFile, inOK := in.(*os.File)
+	outFile, outOK := out.(*os.File)
+	if inOK && outOK && isTerminal(int(inFile.Fd())) && isTerminal(int(outFile.Fd())) {
+		h := loadHistory(historyPath())
+		return &terminalReader{fd: int(inFile.Fd()), editor: newEditor(in, out, h, s.complete)}
 	}
+	return &scannerReader{scanner: bufio.NewScanner(in), out: out}
 }
 
+// Start runs a session reading from in. Bindings persist
+// from one input to the next, and an input is read over
+// several lines until its brackets are balanced. Lines
+// starting with a colon are commands, see :help.
+//
+// On a terminal, lines can be edited and completed with
+// Tab, and are kept in a history file; see historyPath.
+// Calling exit ends the session. What the program prints
+// goes to out as well, until Start returns.
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+	output := evaluator.Output
+	evaluator.Output = out
+	defer func() { evaluator.Output = output }()
+
+	s := &session{env: object.NewEnvironment(), out: out}
+	reader := newLineReader(in, out, s)
+	var source string
 
 	for {
-		fmt.Print(PROMPT)
-		s := scanner.Scan()
-		if !s {
+		prompt := PROMPT
+		if source != "" {
+			prompt = CONTINUATION_PROMPT
+		}
+
+		line, err := reader.ReadLine(prompt)
+		if errors.Is(err, errInterrupted) {
+			source = ""
+			continue
+		}
+		if err != nil {
+			// Whatever is left is run, to show its errors
+			if source != "" {
+				io.WriteString(out, "\n")
+				s.run("", source)
+			}
 			return
 		}
-		line := scanner.Text()
-		lex := lexer.New(line)
-		par := parser.New(lex)
-		prog := par.ParseProgram()
 
-		if err := par.Errors(); len(err) > 0 {
-			printParseErrors(out, err)
+		if source == "" && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			s.command(strings.TrimSpace(line))
+			if s.exited {
+				return
+			}
 			continue
 		}
 
-		ev := evaluator.Eval(prog)
+		source += line + "\n"
+		if incomplete(source) {
+			continue
+		}
+		s.run("", source)
+		source = ""
+		if s.exited {
+			return
+		}
+	}
+}
+
+// run parses and evaluates source in the session
+// environment, writing the result or the problems
+// found. It returns false if there were any.
+func (s *session) run(filename, source string) bool {
+	lex := lexer.NewFile(filename, source)
+	par := parser.New(lex)
+	prog := par.ParseProgram()
+
+	if err := par.Errors(); len(err) > 0 {
+		printDiagnostics(s.out, err, source)
+		return false
+	}
 
-		if ev != nil {
-			io.WriteString(out, ev.Inspect())
-			io.WriteString(out, "\n")
+	ev := evaluator.Eval(prog, s.env)
+
+	if _, ok := ev.(*object.Exit); ok {
+		s.exited = true
+		return true
+	}
+
+	if errObj, ok := ev.(*object.Error); ok {
+		printDiagnostics(s.out, []*diagnostic.Diagnostic{errObj.Diagnostic()}, source)
+		return false
+	}
+
+	if ev != nil {
+		io.WriteString(s.out, ev.Inspect())
+		io.WriteString(s.out, "\n")
+	}
+	return true
+}
+
+// incomplete reports whether source needs more lines:
+// it has unclosed brackets, or ends inside a string or
+// a block comment. Brackets are counted on tokens so
+// the ones in strings and comments are left out.
+func incomplete(source string) bool {
+	if strings.TrimSpace(source) == "" {
+		return false
+	}
+
+	lex := lexer.New(source)
+	depth := 0
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
 		}
+	}
 
+	for _, d := range lex.Errors() {
+		if d.Code == diagnostic.UnterminatedString || d.Code == diagnostic.UnterminatedComment {
+			return true
+		}
+	}
+	return depth > 0
+}
+
+// complete returns the keywords, bindings and builtins
+// that the word ending at pos could be, or the commands
+// when completing the first word of a command line.
+func (s *session) complete(line []rune, pos int) (int, []string) {
+	start := pos
+	for start > 0 && (unicode.IsLetter(line[start-1]) || unicode.IsDigit(line[start-1]) || line[start-1] == '_') {
+		start--
+	}
+	word := string(line[start:pos])
+
+	var names []string
+	if start == 1 && line[0] == ':' {
+		names = commandOrder
+	} else if word != "" {
+		names = slices.Concat(token.Keywords(), s.env.Names(), evaluator.Builtins())
+	}
+
+	var candidates []string
+	for _, name := range names {
+		if strings.HasPrefix(name, word) {
+			candidates = append(candidates, name)
+		}
 	}
+	slices.Sort(candidates)
+	return start, slices.Compact(candidates)
 }
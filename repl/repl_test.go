@@ -0,0 +1,355 @@
+package repl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"nexus/evaluator"
+	"nexus/object"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSessionKeepsBindings(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("let x = 5;\nlet y = x * 2;\nx + y\n"), &out)
+
+	expected := ">>> >>> >>> 15\n>>> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := `let add = fn(a, b) {
+  // a comment with a stray }
+  a + b
+};
+add(1,
+    2)
+"two
+lines"
+/* open
+*/ 4
+`
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">>> ... ... ... >>> ... 3\n>>> ... \"two\\nlines\"\n>>> ... 4\n>>> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"", false},
+		{"1 + 2", false},
+		{"fn(x) {", true},
+		{"fn(x) { x }", false},
+		{"[1, (2", true},
+		{"\"}\" + (", true},
+		{"\"(\"", false},
+		{"x) + 1", false},
+		{"\"open", true},
+		{"/* open", true},
+	}
+
+	for _, tt := range tests {
+		if actual := incomplete(tt.input); actual != tt.expected {
+			t.Errorf("incomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestUnfinishedInputAtEOF(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("if (true) {\n"), &out)
+
+	if !strings.Contains(out.String(), "Expected token to be }, got EOF instead") {
+		t.Errorf("expected the unfinished input to be reported. got=%q", out.String())
+	}
+}
+
+func TestProgramOutput(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("puts(\"hi\")\n"), &out)
+
+	expected := ">>> hi\nnull\n>>> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+	if evaluator.Output != os.Stdout {
+		t.Errorf("output not restored. got=%v", evaluator.Output)
+	}
+}
+
+func TestExitEndsSession(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("1\nexit()\n2\n"), &out)
+
+	expected := ">>> 1\n>>> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "exit.nx")
+	if err := os.WriteFile(path, []byte("exit(1)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []string{":load " + path + "\n2\n", ":time exit(1)\n2\n"} {
+		if out := runSession(input); strings.Contains(out, "2\n") {
+			t.Errorf("%q: session kept running. got=%q", input, out)
+		}
+	}
+}
+
+func runSession(input string) string {
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	return strings.ReplaceAll(out.String(), PROMPT, "")
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			":tokens x+= 1",
+			"1:1     IDENT     \"x\"\n1:2     +=        \"+=\"\n1:5     INT       \"1\"\n",
+		},
+		{
+			":ast -a * b",
+			"((-a) * b)\n" +
+				"Program 1:1\n" +
+				"  ExpressionStatement 1:1\n" +
+				"    InfixExpression * 1:1\n" +
+				"      PrefixExpression - 1:1\n" +
+				"        Identifier a 1:2\n" +
+				"      Identifier b 1:6\n",
+		},
+		{
+			":ast let = 1",
+			"error[P001]: Expected token to be IDENT, got = instead\n" +
+				" --> 1:5\n" +
+				"  |\n" +
+				"1 | let = 1\n" +
+				"  |     ^\n",
+		},
+		{
+			"let b = 2;\nconst A = \"a\";\n:env",
+			"const A = \"a\"\nlet b = 2\n",
+		},
+		{
+			"let b = 2;\n:reset\n:env\nb",
+			"error[R001]: identifier not found: b\n --> 1:1\n  |\n1 | b\n  | ^\n",
+		},
+		{
+			":nope",
+			"unknown command :nope, see :help\n",
+		},
+		{
+			":load",
+			"usage: :load <file>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if actual := runSession(tt.input + "\n"); actual != tt.expected {
+			t.Errorf("wrong output for %q. expected=\n%s\ngot=\n%s", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestHelpListsEveryCommand(t *testing.T) {
+	output := runSession(":help\n")
+	for name, cmd := range commands {
+		if !strings.Contains(output, cmd.usage) {
+			t.Errorf("help does not mention :%s. got=%q", name, output)
+		}
+	}
+}
+
+func TestLoadCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.nx")
+	err := os.WriteFile(path, []byte("let double = fn(x) { x * 2 };\nlet broken = 1 +;\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := runSession(":load " + path + "\n")
+	if !strings.Contains(output, path+":2:17") {
+		t.Errorf("expected the error to point into the file. got=%q", output)
+	}
+
+	os.WriteFile(path, []byte("let double = fn(x) { x * 2 };\n"), 0o644)
+	output = runSession(":load " + path + "\ndouble(21)\n")
+	if output != "42\n" {
+		t.Errorf("expected the file bindings to be usable. got=%q", output)
+	}
+}
+
+func TestTimeCommand(t *testing.T) {
+	output := runSession(":time 1 + 1\n")
+	if !strings.HasPrefix(output, "2\ntook ") {
+		t.Errorf("wrong output. got=%q", output)
+	}
+}
+
+func editLine(input string, h *history, complete completer) (string, error) {
+	e := newEditor(strings.NewReader(input), io.Discard, h, complete)
+	return e.readLine(PROMPT)
+}
+
+func TestEditorKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"abc\r", "abc"},
+		{"bc\x01a\x05d\r", "abcd"},            // Ctrl-A, Ctrl-E
+		{"ac\x1b[Db\x1b[C\x1b[Cd\r", "abcd"},  // Left, right
+		{"abc\x02\x02\x7f\r", "bc"},           // Ctrl-B, backspace
+		{"abc\x1b[H\x1b[3~\x1b[F!\r", "bc!"},  // Home, delete, end
+		{"let x = 10\x17\x17y\r", "let x y"},  // Ctrl-W twice
+		{"foo bar\x02\x02\x02\x0b\r", "foo "}, // Ctrl-K
+		{"foo bar\x02\x02\x02\x15\r", "bar"},  // Ctrl-U
+		{"ab\x01\x04\r", "b"},                 // Ctrl-D deletes
+		{"añ\x02\x02日\r", "日añ"},              // Runes, not bytes
+		{"a\x1b[5~b\x1bxc\r", "abc"},          // Unknown keys are ignored
+	}
+
+	for _, tt := range tests {
+		line, err := editLine(tt.input, &history{}, nil)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("wrong line for %q. expected=%q, got=%q", tt.input, tt.expected, line)
+		}
+	}
+}
+
+func TestEditorEndings(t *testing.T) {
+	if _, err := editLine("\x04", &history{}, nil); err != io.EOF {
+		t.Errorf("expected io.EOF on Ctrl-D, got %v", err)
+	}
+	if _, err := editLine("abc\x03", &history{}, nil); err != errInterrupted {
+		t.Errorf("expected errInterrupted on Ctrl-C, got %v", err)
+	}
+	if _, err := editLine("abc", &history{}, nil); err != io.EOF {
+		t.Errorf("expected io.EOF at the end of input, got %v", err)
+	}
+}
+
+func TestEditorHistory(t *testing.T) {
+	h := &history{lines: []string{"let a = 1", "a + 1", "puts(a)"}}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"\x1b[A\r", "puts(a)"},
+		{"\x1b[A\x1b[A\x1b[A\x1b[A\r", "let a = 1"},
+		{"draft\x1b[A\x1b[A\x1b[B\x1b[B\r", "draft"},
+		{"\x10\x10!\r", "a + 1!"},        // Ctrl-P
+		{"\x12a =\r", "let a = 1"},       // Ctrl-R
+		{"\x12a\x12\x12\r", "let a = 1"}, // Ctrl-R again goes further back
+		{"\x12+\x05 2\r", "a + 1 2"},     // Other keys end the search
+		{"x\x12zzz\x07\r", "x"},          // Ctrl-G gives up
+		{"\x12pu\x7f\x7fa +\r", "a + 1"}, // Backspace widens it again
+	}
+
+	for _, tt := range tests {
+		line, err := editLine(tt.input, h, nil)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("wrong line for %q. expected=%q, got=%q", tt.input, tt.expected, line)
+		}
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	h := loadHistory(path)
+	h.add("let a = 1")
+	h.add("let a = 1")
+	h.add("  ")
+	h.add("a * 2")
+
+	h = loadHistory(path)
+	if !slices.Equal(h.lines, []string{"let a = 1", "a * 2"}) {
+		t.Errorf("wrong history. got=%q", h.lines)
+	}
+
+	var lines []string
+	for i := range 2*historySize + 1 {
+		lines = append(lines, fmt.Sprint(i))
+	}
+	os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+
+	h = loadHistory(path)
+	if len(h.lines) != historySize || h.lines[0] != fmt.Sprint(historySize+1) {
+		t.Errorf("history not cut back. got %d lines, first=%q", len(h.lines), h.lines[0])
+	}
+	if data, _ := os.ReadFile(path); strings.Count(string(data), "\n") != historySize {
+		t.Errorf("history file not cut back")
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	s := &session{env: object.NewEnvironment()}
+	s.env.Set("counter", &object.Integer{Value: 1})
+	s.env.Set("count_all", &object.Integer{Value: 2})
+
+	tests := []struct {
+		line       string
+		start      int
+		candidates []string
+	}{
+		{"con", 0, []string{"const", "continue"}},
+		{"x + cou", 4, []string{"count_all", "counter"}},
+		{"pu", 0, []string{"push", "puts"}},
+		{"le", 0, []string{"len", "let"}},
+		{":he", 1, []string{"help"}},
+		{"x + ", 4, nil},
+		{"zzz", 0, nil},
+	}
+
+	for _, tt := range tests {
+		line := []rune(tt.line)
+		start, candidates := s.complete(line, len(line))
+		if start != tt.start || !slices.Equal(candidates, tt.candidates) {
+			t.Errorf("wrong completion for %q. expected=%d %q, got=%d %q", tt.line, tt.start, tt.candidates, start, candidates)
+		}
+	}
+
+	tabs := []struct {
+		input    string
+		expected string
+	}{
+		{"x = cou\t\r", "x = count"},
+		{"x = coun\te\t\r", "x = counter"},
+		{"whi\t(true)\r", "while(true)"},
+		{":t\t\r", ":t"},
+		{":ti\t 1\r", ":time 1"},
+	}
+	for _, tt := range tabs {
+		line, err := editLine(tt.input, &history{}, s.complete)
+		if err != nil || line != tt.expected {
+			t.Errorf("wrong line for %q. expected=%q, got=%q (%v)", tt.input, tt.expected, line, err)
+		}
+	}
+}
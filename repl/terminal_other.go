@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package repl
+
+import "errors"
+
+// Line editing is not supported here, so
+// input is always read line by line.
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw mode not supported")
+}
@@ -1,10 +1,54 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // Where the token starts
+	End     Position // Just past the token's last character
+}
+
+// Position is a location in the source. Line and Column
+// start at 1, and Column counts runes; Offset and RuneOffset
+// are the 0-based byte and rune offsets.
+type Position struct {
+	Filename   string `json:"file,omitempty"`
+	Offset     int    `json:"offset"`
+	RuneOffset int    `json:"runeOffset"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+}
+
+// IsValid reports whether the position was set.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String renders the position as `file:line:col`, or
+// `line:col` when there is no file name.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Comment is a comment the lexer skipped, kept aside from
+// the tokens so that tools can put it back. Text includes
+// the `//` or `/* */` markers.
+type Comment struct {
+	Text string
+	Pos  Position
+	End  Position
 }
 
 const (
@@ -12,8 +56,10 @@ const (
 	EOF     = "EOF"
 
 	// Literal identifiers
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	FLOAT  = "FLOAT"
+	STRING = "STRING"
 
 	// Operator identifiers
 	ASSIGN = "="
@@ -21,14 +67,31 @@ const (
 	SUBS   = "-"
 	DIV    = "/"
 	MULT   = "*"
+	MOD    = "%"
+	POW    = "**"
+
+	BIT_AND = "&"
+	BIT_OR  = "|"
+	BIT_XOR = "^"
+	BIT_NOT = "~"
+	SHL     = "<<"
+	SHR     = ">>"
+
+	PLUS_ASSIGN = "+="
+	SUBS_ASSIGN = "-="
+	MULT_ASSIGN = "*="
+	DIV_ASSIGN  = "/="
 
 	// Delimitiers
 	COMMA     = ","
+	COLON     = ":"
 	SEMICOLON = ";"
 	LPAREN    = "("
 	RPAREN    = ")"
 	LBRACE    = "{"
 	RBRACE    = "}"
+	LBRACKET  = "["
+	RBRACKET  = "]"
 
 	// Reserved words
 	FUNCTION = "FUNCTION"
@@ -39,6 +102,11 @@ const (
 	ELSE     = "ELSE"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 
 	// Conditionals
 	EQ  = "=="
@@ -46,17 +114,36 @@ const (
 	NEQ = "!="
 	LT  = "<"
 	GT  = ">"
+	LTE = "<="
+	GTE = ">="
+	AND = "&&"
+	OR  = "||"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"return": RET,
-	"const":  CON,
-	"if":     IF,
-	"else":   ELSE,
-	"true":   TRUE,
-	"false":  FALSE,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"return":   RET,
+	"const":    CON,
+	"if":       IF,
+	"else":     ELSE,
+	"true":     TRUE,
+	"false":    FALSE,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+}
+
+// Keywords returns the reserved words, sorted.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
 }
 
 func LookupIdent(ident string) TokenType {